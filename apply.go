@@ -1,13 +1,108 @@
 package dragontoothmg
 
+import (
+	"errors"
+	"fmt"
+)
+
+// The state needed to unmake a move, as returned by MakeMove.
+// It is a small value type, so making and unmaking moves does not allocate.
+type Undo struct {
+	captured      Piece // the piece captured on the destination square (not e.p.)
+	castling      bool
+	castlerights  uint8
+	enpassant     uint8
+	halfmoveclock uint8
+	hash          uint64
+}
+
 // Applies a move to the board, and returns a function that can be used to unapply it.
 // This function assumes that the given move is valid (i.e., is in the set of moves found by GenerateLegalMoves()).
-// If the move is not valid, this function has undefined behavior.
+// If the move is not valid, this function has undefined behavior; use ApplyChecked for untrusted moves.
+// The returned closure allocates; performance-critical code should use MakeMove and UnmakeMove.
 func (b *Board) Apply(m Move) func() {
+	undo := b.MakeMove(m)
+	return func() {
+		b.UnmakeMove(m, undo)
+	}
+}
+
+// Applies a move to the board after checking that it is legal, and returns a function
+// that can be used to unapply it. If the move is not legal, the board is unchanged,
+// and the error describes why the move was rejected.
+func (b *Board) ApplyChecked(m Move) (func(), error) {
+	if err := b.checkMove(m); err != nil {
+		return nil, fmt.Errorf("Illegal move %v: %v", &m, err)
+	}
+	return b.Apply(m), nil
+}
+
+var pieceNames = [...]string{"nothing", "pawn", "knight", "bishop", "rook", "queen", "king"}
+
+// Returns an error explaining why a move is not legal, or nil if it is.
+func (b *Board) checkMove(m Move) error {
+	ourPieces, color := &(b.White), "white"
+	promotionRank := onlyRank[7]
+	if !b.Wtomove {
+		ourPieces, color = &(b.Black), "black"
+		promotionRank = onlyRank[0]
+	}
+	from, to := IndexToAlgebraic(Square(m.From())), IndexToAlgebraic(Square(m.To()))
+	if m == 0 {
+		return errors.New("null move, which must be applied with ApplyNull")
+	}
+	fromBitboard := uint64(1) << m.From()
+	toBitboard := uint64(1) << m.To()
+	if (b.White.All|b.Black.All)&fromBitboard == 0 {
+		return fmt.Errorf("no piece on %v", from)
+	}
+	if ourPieces.All&fromBitboard == 0 {
+		return fmt.Errorf("the piece on %v is not %v's", from, color)
+	}
+	if b.isCastling(m) {
+		if m.Promote() != Nothing {
+			return errors.New("promotion is not allowed when castling")
+		}
+		if b.OurKingInCheck() {
+			return errors.New("king is in check, and can't castle")
+		}
+		if !b.isLegalCastling(m) {
+			return errors.New("castling is not allowed")
+		}
+		return nil
+	}
+	if ourPieces.All&toBitboard != 0 {
+		return fmt.Errorf("%v is occupied by a %v piece", to, color)
+	}
+	pieceType, _ := determinePieceType(ourPieces, fromBitboard)
+	if pieceType == Pawn && toBitboard&promotionRank != 0 {
+		if m.Promote() == Nothing {
+			return errors.New("promotion required")
+		}
+		if m.Promote() < Knight || m.Promote() > Queen {
+			return errors.New("can only promote to a knight, bishop, rook or queen")
+		}
+	} else if m.Promote() != Nothing {
+		return errors.New("only a pawn reaching the last rank can promote")
+	}
+	if !b.IsPseudoLegal(m) {
+		return fmt.Errorf("the %v on %v can't move to %v", pieceNames[pieceType], from, to)
+	}
+	if !b.IsLegal(m) {
+		return errors.New("king would be in check")
+	}
+	return nil
+}
+
+// Applies a move to the board, and returns the state needed to unmake it with UnmakeMove.
+// This function assumes that the given move is valid (i.e., is in the set of moves found by GenerateLegalMoves()).
+// If the move is not valid, this function has undefined behavior.
+func (b *Board) MakeMove(m Move) Undo {
+	undo := Undo{castlerights: b.castlerights, enpassant: b.enpassant,
+		halfmoveclock: b.Halfmoveclock, hash: b.hash}
 	// Configure data about which pieces move
 	var ourBitboardPtr, oppBitboardPtr *Bitboards
-	var epDelta int8                                // add this to the e.p. square to find the captured pawn
-	var oppStartingRankBb, ourStartingRankBb uint64 // the starting rank of out opponent's major pieces
+	var epDelta int8 // add this to the e.p. square to find the captured pawn
 	// the constant that represents the index into pieceSquareZobristC for the pawn of our color
 	var ourPiecesPawnZobristIndex int
 	var oppPiecesPawnZobristIndex int
@@ -15,16 +110,12 @@ func (b *Board) Apply(m Move) func() {
 		ourBitboardPtr = &(b.White)
 		oppBitboardPtr = &(b.Black)
 		epDelta = -8
-		oppStartingRankBb = onlyRank[7]
-		ourStartingRankBb = onlyRank[0]
 		ourPiecesPawnZobristIndex = 0
 		oppPiecesPawnZobristIndex = 6
 	} else {
 		ourBitboardPtr = &(b.Black)
 		oppBitboardPtr = &(b.White)
 		epDelta = 8
-		oppStartingRankBb = onlyRank[0]
-		ourStartingRankBb = onlyRank[7]
 		b.Fullmoveno++ // increment after black's move
 		ourPiecesPawnZobristIndex = 6
 		oppPiecesPawnZobristIndex = 0
@@ -32,73 +123,41 @@ func (b *Board) Apply(m Move) func() {
 	fromBitboard := (uint64(1) << m.From())
 	toBitboard := (uint64(1) << m.To())
 	pieceType, pieceTypeBitboard := determinePieceType(ourBitboardPtr, fromBitboard)
-	castleStatus := 0
-	var oldRookLoc, newRookLoc uint8
-	var flippedKsCastle, flippedQsCastle, flippedOppKsCastle, flippedOppQsCastle bool
+	capturedPieceType, capturedBitboard := determinePieceType(oppBitboardPtr, toBitboard)
+	undo.captured = capturedPieceType
 
 	// If it is any kind of capture or pawn move, reset halfmove clock.
-	resetHalfmoveClockFrom := -1
-	if IsCapture(m, b) || pieceType == Pawn { 
-		resetHalfmoveClockFrom = int(b.Halfmoveclock)
-		b.Halfmoveclock = 0 // reset halfmove clock
+	if capturedPieceType != Nothing || pieceType == Pawn {
+		b.Halfmoveclock = 0
 	} else {
 		b.Halfmoveclock++
 	}
 
-	// King moves strip castling rights
+	// Castling is encoded as the king moving two squares, or (in Chess960)
+	// as the king capturing its own rook.
+	undo.castling = pieceType == King &&
+		(toBitboard&ourBitboardPtr.Rooks != 0 || m.To()-m.From() == 2 || m.From()-m.To() == 2)
+
+	// King moves strip castling rights, as do moves to or from a castling rook's square.
 	if pieceType == King {
-		// TODO(dylhunn): do this without a branch
-		if m.To()-m.From() == 2 { // castle short
-			castleStatus = 1
-			oldRookLoc = m.To() + 1
-			newRookLoc = m.To() - 1
-		} else if int(m.To())-int(m.From()) == -2 { // castle long
-			castleStatus = -1
-			oldRookLoc = m.To() - 2
-			newRookLoc = m.To() + 1
-		}
-		// King moves always strip castling rights
-		if b.canCastleKingside() {
-			b.flipKingsideCastle()
-			flippedKsCastle = true
-		}
-		if b.canCastleQueenside() {
-			b.flipQueensideCastle()
-			flippedQsCastle = true
+		if b.Wtomove {
+			b.castlerights &^= 0x3
+		} else {
+			b.castlerights &^= 0xC
 		}
 	}
-
-	// Rook moves strip castling rights
-	if pieceType == Rook {
-		if b.canCastleKingside() && (fromBitboard&onlyFile[7] != 0) &&
-			fromBitboard&ourStartingRankBb != 0 { // king's rook
-			flippedKsCastle = true
-			b.flipKingsideCastle()
-		} else if b.canCastleQueenside() && (fromBitboard&onlyFile[0] != 0) &&
-			fromBitboard&ourStartingRankBb != 0 { // queen's rook
-			flippedQsCastle = true
-			b.flipQueensideCastle()
+	if b.castlerights != 0 {
+		for i := uint8(0); i < 4; i++ {
+			if b.castlerooks[i] == m.From() || b.castlerooks[i] == m.To() {
+				b.castlerights &^= 1 << i
+			}
 		}
 	}
-
-	// Apply the castling rook movement
-	if castleStatus != 0 {
-		ourBitboardPtr.Rooks |= (uint64(1) << newRookLoc)
-		ourBitboardPtr.All |= (uint64(1) << newRookLoc)
-		ourBitboardPtr.Rooks &= ^(uint64(1) << oldRookLoc)
-		ourBitboardPtr.All &= ^(uint64(1) << oldRookLoc)
-		// Update rook location in hash
-		// (Rook - 1) assumes that "Nothing" precedes "Rook" in the Piece constants list
-		b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(Rook-1)][oldRookLoc]
-		b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(Rook-1)][newRookLoc]
-	}
+	b.hash ^= castleRightsZobrist(undo.castlerights) ^ castleRightsZobrist(b.castlerights)
 
 	// Is this an e.p. capture? Strip the opponent pawn and reset the e.p. square
-	oldEpCaptureSquare := b.enpassant
-	var actuallyPerformedEpCapture bool = false
-	if pieceType == Pawn && m.To() == oldEpCaptureSquare && oldEpCaptureSquare != 0 {
-		actuallyPerformedEpCapture = true
-		epOpponentPawnLocation := uint8(int8(oldEpCaptureSquare) + epDelta)
+	if pieceType == Pawn && m.To() == undo.enpassant && undo.enpassant != 0 {
+		epOpponentPawnLocation := uint8(int8(undo.enpassant) + epDelta)
 		oppBitboardPtr.Pawns &= ^(uint64(1) << epOpponentPawnLocation)
 		oppBitboardPtr.All &= ^(uint64(1) << epOpponentPawnLocation)
 		// Remove the opponent pawn from the board hash.
@@ -112,131 +171,217 @@ func (b *Board) Apply(m Move) func() {
 	}
 
 	// Is this a promotion?
-	var destTypeBitboard *uint64
-	var promotedToPieceType Piece // if not promoted, same as pieceType
-	switch m.Promote() {
-	case Queen:
-		destTypeBitboard = &(ourBitboardPtr.Queens)
-		promotedToPieceType = Queen
-	case Knight:
-		destTypeBitboard = &(ourBitboardPtr.Knights)
-		promotedToPieceType = Knight
-	case Rook:
-		destTypeBitboard = &(ourBitboardPtr.Rooks)
-		promotedToPieceType = Rook
-	case Bishop:
-		destTypeBitboard = &(ourBitboardPtr.Bishops)
-		promotedToPieceType = Bishop
-	default:
-		destTypeBitboard = pieceTypeBitboard
-		promotedToPieceType = pieceType
+	destTypeBitboard := pieceTypeBitboard
+	promotedToPieceType := pieceType // if not promoted, same as pieceType
+	if m.Promote() != Nothing {
+		promotedToPieceType = m.Promote()
+		destTypeBitboard = pieceBitboard(ourBitboardPtr, promotedToPieceType)
 	}
 
 	// Apply the move
-	capturedPieceType, capturedBitboard := determinePieceType(oppBitboardPtr, toBitboard)
-	ourBitboardPtr.All &= ^fromBitboard // remove at "from"
-	ourBitboardPtr.All |= toBitboard    // add at "to"
-	*pieceTypeBitboard &= ^fromBitboard // remove at "from"
-	*destTypeBitboard |= toBitboard     // add at "to"
-	if capturedPieceType != Nothing {   // This does not account for e.p. captures
-		*capturedBitboard &= ^toBitboard
-		oppBitboardPtr.All &= ^toBitboard
-		b.hash ^= pieceSquareZobristC[oppPiecesPawnZobristIndex+(int(capturedPieceType)-1)][m.To()] // remove the captured piece from the hash
-	}
-	b.hash ^= pieceSquareZobristC[(int(pieceType)-1)+ourPiecesPawnZobristIndex][m.From()]         // remove piece at "from"
-	b.hash ^= pieceSquareZobristC[(int(promotedToPieceType)-1)+ourPiecesPawnZobristIndex][m.To()] // add piece at "to"
-
-	// If a rook was captured, it strips castling rights
-	if capturedPieceType == Rook {
-		if m.To()%8 == 7 && toBitboard&oppStartingRankBb != 0 && b.oppCanCastleKingside() { // captured king rook
-			b.flipOppKingsideCastle()
-			flippedOppKsCastle = true
-		} else if m.To()%8 == 0 && toBitboard&oppStartingRankBb != 0 && b.oppCanCastleQueenside() { // queen rooks
-			b.flipOppQueensideCastle()
-			flippedOppQsCastle = true
+	if undo.castling {
+		// The king and rook may land on each other's starting squares, so
+		// remove both pieces before placing them.
+		kingTo, rookFrom, rookTo := b.castlingSquares(m, b.Wtomove)
+		ourBitboardPtr.Kings ^= fromBitboard ^ (uint64(1) << kingTo)
+		ourBitboardPtr.Rooks ^= (uint64(1) << rookFrom) ^ (uint64(1) << rookTo)
+		ourBitboardPtr.All &= ^(fromBitboard | (uint64(1) << rookFrom))
+		ourBitboardPtr.All |= (uint64(1) << kingTo) | (uint64(1) << rookTo)
+		// (King - 1) and (Rook - 1) assume that "Nothing" precedes them in the Piece constants list
+		b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(King-1)][m.From()]
+		b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(King-1)][kingTo]
+		b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(Rook-1)][rookFrom]
+		b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(Rook-1)][rookTo]
+	} else {
+		ourBitboardPtr.All &= ^fromBitboard // remove at "from"
+		ourBitboardPtr.All |= toBitboard    // add at "to"
+		*pieceTypeBitboard &= ^fromBitboard // remove at "from"
+		*destTypeBitboard |= toBitboard     // add at "to"
+		if capturedPieceType != Nothing {   // This does not account for e.p. captures
+			*capturedBitboard &= ^toBitboard
+			oppBitboardPtr.All &= ^toBitboard
+			b.hash ^= pieceSquareZobristC[oppPiecesPawnZobristIndex+(int(capturedPieceType)-1)][m.To()] // remove the captured piece from the hash
 		}
+		b.hash ^= pieceSquareZobristC[(int(pieceType)-1)+ourPiecesPawnZobristIndex][m.From()]         // remove piece at "from"
+		b.hash ^= pieceSquareZobristC[(int(promotedToPieceType)-1)+ourPiecesPawnZobristIndex][m.To()] // add piece at "to"
 	}
+
 	// flip the side to move in the hash
 	b.hash ^= whiteToMoveZobristC
 	b.Wtomove = !b.Wtomove
 
 	// remove the old en passant square from the hash, and add the new one
-	b.hash ^= uint64(oldEpCaptureSquare)
+	b.hash ^= uint64(undo.enpassant)
 	b.hash ^= uint64(b.enpassant)
+	return undo
+}
 
-	// Return the unapply function (closure)
-	unapply := func() {
-		// Flip the player to move
-		b.hash ^= whiteToMoveZobristC
-		b.Wtomove = !b.Wtomove
-
-		// Restore the halfmove clock
-		if resetHalfmoveClockFrom == -1 {
-			b.Halfmoveclock--
-		} else {
-			b.Halfmoveclock = uint8(resetHalfmoveClockFrom)
-		}
+// Unmakes a move made by MakeMove, restoring the board to its previous state.
+// The move and undo state must be those of the most recently made move.
+func (b *Board) UnmakeMove(m Move, undo Undo) {
+	// Flip the player to move
+	b.Wtomove = !b.Wtomove
+	var ourBitboardPtr, oppBitboardPtr *Bitboards
+	var epDelta int8
+	if b.Wtomove {
+		ourBitboardPtr = &(b.White)
+		oppBitboardPtr = &(b.Black)
+		epDelta = -8
+	} else {
+		ourBitboardPtr = &(b.Black)
+		oppBitboardPtr = &(b.White)
+		epDelta = 8
+		b.Fullmoveno-- // decrement after undoing black's move
+	}
+	fromBitboard := (uint64(1) << m.From())
+	toBitboard := (uint64(1) << m.To())
 
+	if undo.castling {
+		// Restore the king and rook from a castling move
+		kingTo, rookFrom, rookTo := b.castlingSquares(m, b.Wtomove)
+		ourBitboardPtr.Kings ^= fromBitboard ^ (uint64(1) << kingTo)
+		ourBitboardPtr.Rooks ^= (uint64(1) << rookFrom) ^ (uint64(1) << rookTo)
+		ourBitboardPtr.All &= ^((uint64(1) << kingTo) | (uint64(1) << rookTo))
+		ourBitboardPtr.All |= fromBitboard | (uint64(1) << rookFrom)
+	} else {
 		// Unapply move
-		ourBitboardPtr.All &= ^toBitboard                                                             // remove at "to"
-		ourBitboardPtr.All |= fromBitboard                                                            // add at "from"
-		*destTypeBitboard &= ^toBitboard                                                              // remove at "to"
-		*pieceTypeBitboard |= fromBitboard                                                            // add at "from"
-		b.hash ^= pieceSquareZobristC[(int(promotedToPieceType)-1)+ourPiecesPawnZobristIndex][m.To()] // remove the piece at "to"
-		b.hash ^= pieceSquareZobristC[(int(pieceType)-1)+ourPiecesPawnZobristIndex][m.From()]         // add the piece at "from"
+		destType, destTypeBitboard := determinePieceType(ourBitboardPtr, toBitboard)
+		pieceType, pieceTypeBitboard := destType, destTypeBitboard
+		if m.Promote() != Nothing {
+			pieceType, pieceTypeBitboard = Pawn, &(ourBitboardPtr.Pawns)
+		}
+		ourBitboardPtr.All &= ^toBitboard  // remove at "to"
+		ourBitboardPtr.All |= fromBitboard // add at "from"
+		*destTypeBitboard &= ^toBitboard   // remove at "to"
+		*pieceTypeBitboard |= fromBitboard // add at "from"
 
 		// Restore captured piece (excluding e.p.)
-		if capturedPieceType != Nothing { // doesn't consider e.p. captures
-			*capturedBitboard |= toBitboard
+		if undo.captured != Nothing {
+			*pieceBitboard(oppBitboardPtr, undo.captured) |= toBitboard
 			oppBitboardPtr.All |= toBitboard
-			// restore the captured piece to the hash (excluding e.p.)
-			b.hash ^= pieceSquareZobristC[oppPiecesPawnZobristIndex+(int(capturedPieceType)-1)][m.To()]
 		}
 
-		// Restore rooks from castling move
-		if castleStatus != 0 {
-			ourBitboardPtr.Rooks &= ^(uint64(1) << newRookLoc)
-			ourBitboardPtr.All &= ^(uint64(1) << newRookLoc)
-			ourBitboardPtr.Rooks |= (uint64(1) << oldRookLoc)
-			ourBitboardPtr.All |= (uint64(1) << oldRookLoc)
-			// Revert castling rook move
-			b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(Rook-1)][oldRookLoc]
-			b.hash ^= pieceSquareZobristC[ourPiecesPawnZobristIndex+(Rook-1)][newRookLoc]
-		}
-
-		// Unapply en-passant square change, and capture if necessary
-		b.hash ^= uint64(b.enpassant)        // undo the new en passant square from the hash
-		b.hash ^= uint64(oldEpCaptureSquare) // restore the old one to the hash
-		b.enpassant = oldEpCaptureSquare
-		if actuallyPerformedEpCapture {
-			epOpponentPawnLocation := uint8(int8(oldEpCaptureSquare) + epDelta)
+		// Restore the pawn captured en passant
+		if pieceType == Pawn && m.To() == undo.enpassant && undo.enpassant != 0 {
+			epOpponentPawnLocation := uint8(int8(undo.enpassant) + epDelta)
 			oppBitboardPtr.Pawns |= (uint64(1) << epOpponentPawnLocation)
 			oppBitboardPtr.All |= (uint64(1) << epOpponentPawnLocation)
-			// Add the opponent pawn to the board hash.
-			b.hash ^= pieceSquareZobristC[oppPiecesPawnZobristIndex][epOpponentPawnLocation]
 		}
+	}
 
-		// Decrement move clock
-		if !b.Wtomove {
-			b.Fullmoveno-- // decrement after undoing black's move
-		}
+	b.castlerights = undo.castlerights
+	b.enpassant = undo.enpassant
+	b.Halfmoveclock = undo.halfmoveclock
+	b.hash = undo.hash
+}
 
-		// Restore castling flags
-		// Must update castling flags AFTER turn swap
-		if flippedKsCastle {
-			b.flipKingsideCastle()
-		}
-		if flippedQsCastle {
-			b.flipQueensideCastle()
-		}
-		if flippedOppKsCastle {
-			b.flipOppKingsideCastle()
-		}
-		if flippedOppQsCastle {
-			b.flipOppQueensideCastle()
-		}
+// Passes the turn to the opponent, as in null-move pruning, and returns a function
+// that can be used to undo the pass. A null move can't be made while in check.
+func (b *Board) ApplyNull() (func(), error) {
+	undo, err := b.MakeNullMove()
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		b.UnmakeNullMove(undo)
+	}, nil
+}
+
+// Passes the turn to the opponent, and returns the state needed to undo the pass
+// with UnmakeNullMove. The en passant square is cleared, and the hash and move
+// counters are updated as for an ordinary quiet move.
+func (b *Board) MakeNullMove() (Undo, error) {
+	if b.OurKingInCheck() {
+		return Undo{}, errors.New("Can't make a null move while in check")
+	}
+	undo := Undo{castlerights: b.castlerights, enpassant: b.enpassant,
+		halfmoveclock: b.Halfmoveclock, hash: b.hash}
+	if !b.Wtomove {
+		b.Fullmoveno++ // increment after black's move
+	}
+	b.Halfmoveclock++
+	b.hash ^= whiteToMoveZobristC
+	b.hash ^= uint64(b.enpassant) // remove the en passant square from the hash
+	b.enpassant = 0
+	b.Wtomove = !b.Wtomove
+	return undo, nil
+}
+
+// Undoes a null move made by MakeNullMove. The undo state must be that of the
+// most recent move.
+func (b *Board) UnmakeNullMove(undo Undo) {
+	b.Wtomove = !b.Wtomove
+	if !b.Wtomove {
+		b.Fullmoveno-- // decrement after undoing black's move
+	}
+	b.enpassant = undo.enpassant
+	b.Halfmoveclock = undo.halfmoveclock
+	b.hash = undo.hash
+}
+
+// Returns the hash contribution of a set of castling rights.
+func castleRightsZobrist(rights uint8) uint64 {
+	var hash uint64
+	if rights&0x2 != 0 {
+		hash ^= castleRightsZobristC[0]
+	}
+	if rights&0x1 != 0 {
+		hash ^= castleRightsZobristC[1]
+	}
+	if rights&0x8 != 0 {
+		hash ^= castleRightsZobristC[2]
+	}
+	if rights&0x4 != 0 {
+		hash ^= castleRightsZobristC[3]
+	}
+	return hash
+}
+
+// Returns the king's destination, and the rook's origin and destination, for a
+// castling move by the given side. Castling short moves the king to the g-file and
+// the rook to the f-file; castling long moves them to the c-file and d-file.
+func (b *Board) castlingSquares(m Move, white bool) (kingTo uint8, rookFrom uint8, rookTo uint8) {
+	var backRank uint8 = 56
+	rightIdx := 2 // the castlerights bit for castling long
+	if white {
+		backRank = 0
+		rightIdx = 0
+	}
+	if m.To() > m.From() { // castle short
+		return backRank + 6, b.castlerooks[rightIdx+1], backRank + 5
+	}
+	return backRank + 2, b.castlerooks[rightIdx], backRank + 3 // castle long
+}
+
+// Determines whether a move by the side to move is castling.
+func (b *Board) isCastling(m Move) bool {
+	ourPieces := &(b.Black)
+	if b.Wtomove {
+		ourPieces = &(b.White)
+	}
+	if ourPieces.Kings&(uint64(1)<<m.From()) == 0 {
+		return false
+	}
+	return ourPieces.Rooks&(uint64(1)<<m.To()) != 0 || m.To()-m.From() == 2 || m.From()-m.To() == 2
+}
+
+// Returns a pointer to the bitboard for a given piece type.
+func pieceBitboard(bitboards *Bitboards, pieceType Piece) *uint64 {
+	switch pieceType {
+	case Pawn:
+		return &(bitboards.Pawns)
+	case Knight:
+		return &(bitboards.Knights)
+	case Bishop:
+		return &(bitboards.Bishops)
+	case Rook:
+		return &(bitboards.Rooks)
+	case Queen:
+		return &(bitboards.Queens)
+	case King:
+		return &(bitboards.Kings)
 	}
-	return unapply
+	return &(bitboards.All)
 }
 
 func determinePieceType(ourBitboardPtr *Bitboards, squareMask uint64) (Piece, *uint64) {
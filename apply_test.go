@@ -138,3 +138,129 @@ func TestApplyUnapply(t *testing.T) {
 		}*/
 	}
 }
+
+func TestMakeUnmakeMove(t *testing.T) {
+	fens := []string{
+		Startpos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nPB5/B1P1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"r3k3/1ppp1ppr/8/8/2Pp4/8/1P2PPPP/R3K2R b - c3 0 1",
+	}
+	for _, fen := range fens {
+		b := ParseFen(fen)
+		for _, mv := range b.GenerateLegalMoves() {
+			applied := ParseFen(fen)
+			unapply := applied.Apply(mv)
+			undo := b.MakeMove(mv)
+			if b != applied {
+				t.Error("MakeMove and Apply disagree for", fen, "with move", &mv,
+					"\nMakeMove:", b.ToFen(), "\nApply:   ", applied.ToFen())
+			}
+			if b.Hash() != recomputeBoardHash(&b) {
+				t.Error("MakeMove produced an inconsistent hash for", fen, "with move", &mv)
+			}
+			b.UnmakeMove(mv, undo)
+			unapply()
+			if b.ToFen() != fen || b != applied {
+				t.Error("UnmakeMove didn't restore", fen, "with move", &mv, "\nGot:", b.ToFen())
+			}
+			if b.Hash() != recomputeBoardHash(&b) {
+				t.Error("UnmakeMove produced an inconsistent hash for", fen, "with move", &mv)
+			}
+		}
+	}
+}
+
+func TestApplyChecked(t *testing.T) {
+	tests := []struct {
+		fen  string
+		move string
+		err  string // empty if the move is legal
+	}{
+		{Startpos, "e2e4", ""},
+		{Startpos, "e3e4", "Illegal move e3e4: no piece on e3"},
+		{Startpos, "0000", "Illegal move 0000: null move, which must be applied with ApplyNull"},
+		{Startpos, "e7e5", "Illegal move e7e5: the piece on e7 is not white's"},
+		{Startpos, "e2e5", "Illegal move e2e5: the pawn on e2 can't move to e5"},
+		{Startpos, "d1d2", "Illegal move d1d2: d2 is occupied by a white piece"},
+		{Startpos, "g1f3q", "Illegal move g1f3q: only a pawn reaching the last rank can promote"},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8", "Illegal move b7b8: promotion required"},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8n", ""},
+		{"4k3/8/8/8/8/8/5r2/4K3 w - - 0 1", "e1f1", "Illegal move e1f1: king would be in check"},
+		{"4k3/4r3/8/8/8/8/4B3/4K3 w - - 0 1", "e2d3", "Illegal move e2d3: king would be in check"},
+		{"4k3/8/8/K2pP2q/8/8/8/8 w - d6 0 1", "e5d6", "Illegal move e5d6: king would be in check"},
+		{"4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", "e1c1", ""},
+		{"4k3/8/8/8/8/8/8/R3K2R w Q - 0 1", "e1g1", "Illegal move e1g1: castling is not allowed"},
+		{"4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", "e1g1q", "Illegal move e1g1q: promotion is not allowed when castling"},
+		{"4r1k1/8/8/8/8/8/8/R3K2R w KQ - 0 1", "e1g1", "Illegal move e1g1: king is in check, and can't castle"},
+	}
+	for _, test := range tests {
+		b := ParseFen(test.fen)
+		unapply, err := b.ApplyChecked(parseMove(test.move))
+		if test.err == "" {
+			if err != nil {
+				t.Error("ApplyChecked of", test.move, "in", test.fen, "failed:", err)
+				continue
+			}
+			unapply()
+		} else if err == nil || err.Error() != test.err {
+			t.Error("ApplyChecked of", test.move, "in", test.fen, "returned", err, "; expected", test.err)
+		}
+		if b.ToFen() != test.fen {
+			t.Error("ApplyChecked of", test.move, "left the board as", b.ToFen(), "; expected", test.fen)
+		}
+	}
+
+	// Every move encoding is accepted exactly when it is legal
+	b := ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	legal := make(map[Move]bool)
+	for _, m := range b.GenerateLegalMoves() {
+		legal[m] = true
+	}
+	for m := Move(0); m < 1<<15; m++ {
+		unapply, err := b.ApplyChecked(m)
+		if (err == nil) != legal[m] {
+			t.Error("ApplyChecked of", &m, "returned", err)
+		}
+		if err == nil {
+			unapply()
+		}
+	}
+}
+
+func TestApplyNull(t *testing.T) {
+	tests := map[string]string{
+		Startpos: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 1 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3": "rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR b KQkq - 1 3",
+		"rnbqkbnr/pppp1ppp/8/8/3Pp3/8/PPP1PPPP/RNBQKBNR b KQkq d3 0 2":  "rnbqkbnr/pppp1ppp/8/8/3Pp3/8/PPP1PPPP/RNBQKBNR w KQkq - 1 3",
+	}
+	for fen, expected := range tests {
+		b := ParseFen(fen)
+		unapply, err := b.ApplyNull()
+		if err != nil {
+			t.Error("ApplyNull in", fen, "failed:", err)
+			continue
+		}
+		if b.ToFen() != expected {
+			t.Error("ApplyNull in", fen, "produced", b.ToFen(), "; expected", expected)
+		}
+		if after := ParseFen(expected); b.Hash() != after.Hash() || b.Hash() != recomputeBoardHash(&b) {
+			t.Error("ApplyNull in", fen, "produced an inconsistent hash")
+		}
+		unapply()
+		if original := ParseFen(fen); b != original {
+			t.Error("Unapplying a null move in", fen, "produced", b.ToFen())
+		}
+	}
+
+	fen := "4k3/8/8/8/8/8/8/r3K3 w - - 0 1"
+	b := ParseFen(fen)
+	if _, err := b.ApplyNull(); err == nil {
+		t.Error("ApplyNull succeeded in check in", fen)
+	}
+	if b.ToFen() != fen {
+		t.Error("ApplyNull in check changed the board to", b.ToFen())
+	}
+}
@@ -0,0 +1,42 @@
+package dragontoothmg
+
+// Returns the pieces of both colors that attack a square, with sliders
+// blocked by the given occupancy. Pass the board's occupancy for the current
+// attackers, or remove pieces from it to find the x-ray attackers behind them.
+// Intersect the result with Board.White.All or Board.Black.All to get the
+// attackers of one color.
+func (b *Board) AttackersTo(sq Square, occupancy uint64) uint64 {
+	diagSliders := b.White.Bishops | b.White.Queens | b.Black.Bishops | b.Black.Queens
+	orthoSliders := b.White.Rooks | b.White.Queens | b.Black.Rooks | b.Black.Queens
+	// A white pawn attacks the square if a black pawn there would attack it
+	return (PawnAttacks(sq, false) & b.White.Pawns) | (PawnAttacks(sq, true) & b.Black.Pawns) |
+		(knightMasks[sq] & (b.White.Knights | b.Black.Knights)) |
+		(kingMasks[sq] & (b.White.Kings | b.Black.Kings)) |
+		(CalculateBishopMoveBitboard(uint8(sq), occupancy) & diagSliders) |
+		(CalculateRookMoveBitboard(uint8(sq), occupancy) & orthoSliders)
+}
+
+// Returns the set of squares attacked by one color, whether they are empty or
+// occupied by either color. Pinned pieces are still counted as attacking.
+func (b *Board) AttackedSquares(white bool) uint64 {
+	return b.attackedSquares(!white, b.White.All|b.Black.All)
+}
+
+// Returns the squares attacked by a pawn of the given color on a square.
+func PawnAttacks(sq Square, white bool) uint64 {
+	pawn := uint64(1) << sq
+	if white {
+		return (pawn << 9 & ^(onlyFile[0])) | (pawn << 7 & ^(onlyFile[7]))
+	}
+	return (pawn >> 7 & ^(onlyFile[0])) | (pawn >> 9 & ^(onlyFile[7]))
+}
+
+// Returns the squares attacked by a knight on a square.
+func KnightAttacks(sq Square) uint64 {
+	return knightMasks[sq]
+}
+
+// Returns the squares attacked by a king on a square.
+func KingAttacks(sq Square) uint64 {
+	return kingMasks[sq]
+}
@@ -0,0 +1,87 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Lists the squares of a bitboard in algebraic notation, in order
+func squareNames(bitboard uint64) string {
+	var names []string
+	for ; bitboard != 0; bitboard &= bitboard - 1 {
+		names = append(names, IndexToAlgebraic(Square(bits.TrailingZeros64(bitboard))))
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func TestAttackersTo(t *testing.T) {
+	b := ParseFen("r3k3/8/8/3p4/4P3/2N5/6B1/3RK3 w - - 0 1")
+	occupancy := b.White.All | b.Black.All
+	e4 := uint64(1) << algebraicToIndexFatal("e4")
+	tests := []struct {
+		square    string
+		occupancy uint64
+		expected  string
+	}{
+		{"d5", occupancy, "c3 d1 e4"},
+		{"d5", occupancy &^ e4, "c3 d1 e4 g2"}, // the bishop behind the pawn
+		{"d8", occupancy, "a8 e8"},
+		{"f2", occupancy, "e1"},
+		{"e4", occupancy, "c3 d5 g2"},
+		{"h8", occupancy, ""},
+	}
+	for _, test := range tests {
+		sq := Square(algebraicToIndexFatal(test.square))
+		if result := squareNames(b.AttackersTo(sq, test.occupancy)); result != test.expected {
+			t.Error("Attackers of", test.square, "are", result, "; expected", test.expected)
+		}
+	}
+}
+
+func TestAttackedSquares(t *testing.T) {
+	positions := []string{
+		Startpos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	}
+	for _, fen := range positions {
+		b := ParseFen(fen)
+		occupancy := b.White.All | b.Black.All
+		for _, white := range []bool{true, false} {
+			side := b.Black.All
+			if white {
+				side = b.White.All
+			}
+			var expected uint64
+			for sq := Square(0); sq < 64; sq++ {
+				if b.AttackersTo(sq, occupancy)&side != 0 {
+					expected |= uint64(1) << sq
+				}
+			}
+			if attacked := b.AttackedSquares(white); attacked != expected {
+				t.Error("Squares attacked by white:", white, "in", fen, "are", squareNames(attacked),
+					"; expected", squareNames(expected))
+			}
+		}
+	}
+}
+
+func TestPieceAttacks(t *testing.T) {
+	tests := map[string]string{
+		squareNames(PawnAttacks(Square(algebraicToIndexFatal("e4")), true)):  "d5 f5",
+		squareNames(PawnAttacks(Square(algebraicToIndexFatal("a2")), true)):  "b3",
+		squareNames(PawnAttacks(Square(algebraicToIndexFatal("h7")), false)): "g6",
+		squareNames(PawnAttacks(Square(algebraicToIndexFatal("h8")), true)):  "",
+		squareNames(KnightAttacks(Square(algebraicToIndexFatal("a1")))):      "b3 c2",
+		squareNames(KingAttacks(Square(algebraicToIndexFatal("h8")))):        "g7 g8 h7",
+	}
+	for result, expected := range tests {
+		if result != expected {
+			t.Error("Attacked squares are", result, "; expected", expected)
+		}
+	}
+}
@@ -33,6 +33,10 @@ func main() {
 	printResultLine(testing.Benchmark(benchmarkKiwipete), "Kiwipete position", kpResult, 5)
 	printResultLine(testing.Benchmark(benchmarkDense), "Dense position", denseResult, 6)
 	printResultLine(testing.Benchmark(benchmarkEndgameRP), "Endgame R/P position", endgameResult, 7)
+	fmt.Println("\nALLOCATIONS (Kiwipete position)")
+	printAllocsLine(testing.Benchmark(benchmarkGenerateLegalMoves), "GenerateLegalMoves")
+	printAllocsLine(testing.Benchmark(benchmarkGenerateLegalMovesInto), "GenerateLegalMovesInto")
+	printAllocsLine(testing.Benchmark(benchmarkPerftAllocs), "Perft depth 3")
 	fmt.Println()
 }
 
@@ -41,6 +45,11 @@ func printResultLine(res testing.BenchmarkResult, name string, perftValue int64,
 		perftValue, float64(perftValue) / (float64(res.NsPerOp()) / nsPerS))
 }
 
+func printAllocsLine(res testing.BenchmarkResult, name string) {
+	fmt.Printf("%-24s %10dns/op %6d allocs/op %8d B/op\n", name + ":", res.NsPerOp(),
+		res.AllocsPerOp(), res.AllocedBytesPerOp())
+}
+
 // -----------------
 // BENCHMARK HELPERS
 // -----------------
@@ -89,3 +98,33 @@ func benchmarkEndgameRP(b *testing.B) {
 		endgameResult = dragontoothmg.Perft(&board, 7)
 	}
 }
+
+const kiwipete = "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0"
+
+var movesResult []dragontoothmg.Move
+func benchmarkGenerateLegalMoves(b *testing.B) {
+	board := dragontoothmg.ParseFen(kiwipete)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		movesResult = board.GenerateLegalMoves()
+	}
+}
+
+var movesCountResult int
+func benchmarkGenerateLegalMovesInto(b *testing.B) {
+	board := dragontoothmg.ParseFen(kiwipete)
+	var buf [256]dragontoothmg.Move
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		movesCountResult = len(board.GenerateLegalMovesInto(buf[:0]))
+	}
+}
+
+var perftAllocsResult int64 = 0
+func benchmarkPerftAllocs(b *testing.B) {
+	board := dragontoothmg.ParseFen(kiwipete)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		perftAllocsResult = dragontoothmg.Perft(&board, 3)
+	}
+}
@@ -0,0 +1,155 @@
+// Package book reads opening books in the Polyglot (.bin) format.
+//
+// A Polyglot book is a sorted list of 16-byte entries, each holding the
+// Polyglot hash of a position, a move, a weight and a learning value.
+// Entries are looked up by binary search, and their moves are decoded into
+// dragontoothmg moves.
+package book
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+const entrySize = 16
+
+// An Entry is a book move for a position, with its weight. Moves with higher
+// weights should be played more often.
+type Entry struct {
+	Move   dragontoothmg.Move
+	Weight uint16
+	Learn  uint32 // Learning data, which most books leave as zero
+}
+
+// A Book is a Polyglot opening book held in memory.
+type Book struct {
+	data []byte
+}
+
+// Returns the key of a position in a Polyglot book.
+func Key(b *dragontoothmg.Board) uint64 {
+	return b.PolyglotHash()
+}
+
+// Loads a Polyglot book from a file.
+func Open(path string) (*Book, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(data)
+}
+
+// Reads a Polyglot book from r, until the end of the input.
+func Load(r io.Reader) (*Book, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return New(data)
+}
+
+// Creates a book from the contents of a Polyglot file. The data is not copied,
+// and must not be modified while the book is in use.
+func New(data []byte) (*Book, error) {
+	if len(data)%entrySize != 0 {
+		return nil, fmt.Errorf("book: size %d is not a multiple of %d bytes", len(data), entrySize)
+	}
+	return &Book{data: data}, nil
+}
+
+// Returns the number of entries in the book.
+func (bk *Book) Len() int {
+	return len(bk.data) / entrySize
+}
+
+// Returns the book entries for a position, in the order they appear in the book.
+// Entries whose moves are not legal in the position are skipped, since they
+// can only come from a hash collision or a corrupt book.
+func (bk *Book) Entries(b *dragontoothmg.Board) []Entry {
+	key := Key(b)
+	n := bk.Len()
+	first := sort.Search(n, func(i int) bool { return bk.key(i) >= key })
+	var entries []Entry
+	var legalMoves []dragontoothmg.Move
+	for i := first; i < n && bk.key(i) == key; i++ {
+		if legalMoves == nil {
+			legalMoves = b.GenerateLegalMoves()
+		}
+		entry := bk.data[i*entrySize : (i+1)*entrySize]
+		m := decodeMove(b, binary.BigEndian.Uint16(entry[8:10]))
+		for _, legal := range legalMoves {
+			if legal == m {
+				entries = append(entries, Entry{
+					Move:   m,
+					Weight: binary.BigEndian.Uint16(entry[10:12]),
+					Learn:  binary.BigEndian.Uint32(entry[12:16]),
+				})
+				break
+			}
+		}
+	}
+	return entries
+}
+
+// Picks a book move for the position at random, with probability proportional
+// to its weight. The second result is false if the position has no book moves
+// with nonzero weight. If r is nil, the default source from math/rand is used.
+func (bk *Book) Pick(b *dragontoothmg.Board, r *rand.Rand) (dragontoothmg.Move, bool) {
+	entries := bk.Entries(b)
+	total := 0
+	for _, e := range entries {
+		total += int(e.Weight)
+	}
+	if total == 0 {
+		return 0, false
+	}
+	var choice int
+	if r != nil {
+		choice = r.Intn(total)
+	} else {
+		choice = rand.Intn(total)
+	}
+	for _, e := range entries {
+		choice -= int(e.Weight)
+		if choice < 0 {
+			return e.Move, true
+		}
+	}
+	panic("unreachable")
+}
+
+// The key of the ith entry.
+func (bk *Book) key(i int) uint64 {
+	return binary.BigEndian.Uint64(bk.data[i*entrySize:])
+}
+
+// Decodes a Polyglot move. The from and to squares use the same layout as
+// dragontoothmg.Move, but promotions are numbered from the knight, and castling
+// is written as the king capturing its own rook. For standard chess, castling
+// is translated to the king moving two squares.
+func decodeMove(b *dragontoothmg.Board, pm uint16) dragontoothmg.Move {
+	var m dragontoothmg.Move
+	from := dragontoothmg.Square(pm >> 6 & 63)
+	to := dragontoothmg.Square(pm & 63)
+	if promote := dragontoothmg.Piece(pm >> 12 & 7); promote != 0 {
+		m.Setpromote(promote + dragontoothmg.Pawn)
+	}
+	kingOnE1 := from == 4 && b.White.Kings&(1<<from) != 0
+	kingOnE8 := from == 60 && b.Black.Kings&(1<<from) != 0
+	if !b.Chess960 && (kingOnE1 || kingOnE8) {
+		if to == from+3 {
+			to = from + 2
+		} else if to == from-4 {
+			to = from - 2
+		}
+	}
+	m.Setfrom(from).Setto(to)
+	return m
+}
@@ -0,0 +1,128 @@
+package book
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+type testEntry struct {
+	fen    string
+	move   uint16 // in Polyglot encoding
+	weight uint16
+}
+
+// Encodes a move in the Polyglot format, from long algebraic notation
+func polyglotMove(s string) uint16 {
+	m, err := dragontoothmg.ParseMove(s)
+	if err != nil {
+		panic(err)
+	}
+	pm := uint16(m.From())<<6 | uint16(m.To())
+	if m.Promote() != dragontoothmg.Nothing {
+		pm |= uint16(m.Promote()-dragontoothmg.Pawn) << 12
+	}
+	return pm
+}
+
+// Builds the contents of a Polyglot book, sorted by key
+func makeBook(entries []testEntry) []byte {
+	var buf bytes.Buffer
+	sorted := make([]testEntry, len(entries))
+	copy(sorted, entries)
+	key := func(e testEntry) uint64 {
+		b := dragontoothmg.ParseFen(e.fen)
+		return Key(&b)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return key(sorted[i]) < key(sorted[j]) })
+	for _, e := range sorted {
+		binary.Write(&buf, binary.BigEndian, key(e))
+		binary.Write(&buf, binary.BigEndian, e.move)
+		binary.Write(&buf, binary.BigEndian, e.weight)
+		binary.Write(&buf, binary.BigEndian, uint32(0))
+	}
+	return buf.Bytes()
+}
+
+const castlingFen = "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"
+
+var testBook = []testEntry{
+	{dragontoothmg.Startpos, polyglotMove("e2e4"), 3},
+	{dragontoothmg.Startpos, polyglotMove("d2d4"), 1},
+	{dragontoothmg.Startpos, polyglotMove("e2e5"), 5}, // illegal, so it is skipped
+	{castlingFen, polyglotMove("e1h1"), 2},
+	{castlingFen, polyglotMove("e1a1"), 1},
+	{castlingFen, polyglotMove("a1a8"), 0},
+	{"k7/4P3/8/8/8/8/8/K7 w - - 0 1", polyglotMove("e7e8n"), 1},
+}
+
+func TestEntries(t *testing.T) {
+	bk, err := New(makeBook(testBook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bk.Len() != len(testBook) {
+		t.Error("Book has", bk.Len(), "entries; expected", len(testBook))
+	}
+	expected := map[string]string{
+		dragontoothmg.Startpos:          "e2e4:3 d2d4:1",
+		castlingFen:                     "e1g1:2 e1c1:1 a1a8:0",
+		"k7/4P3/8/8/8/8/8/K7 w - - 0 1": "e7e8n:1",
+		"k7/8/8/8/8/8/8/K7 w - - 0 1":   "",
+	}
+	for fen, moves := range expected {
+		b := dragontoothmg.ParseFen(fen)
+		var result []string
+		for _, e := range bk.Entries(&b) {
+			result = append(result, e.Move.String()+":"+strconv.Itoa(int(e.Weight)))
+		}
+		if strings.Join(result, " ") != moves {
+			t.Error("Book entries for", fen, "were", result, "; expected", moves)
+		}
+	}
+
+	// Castling is not translated for Chess960 boards
+	b := dragontoothmg.ParseFen(castlingFen)
+	b.Chess960 = true
+	if entries := bk.Entries(&b); len(entries) != 3 || entries[0].Move.String() != "e1h1" {
+		t.Error("Wrong Chess960 castling entries:", entries)
+	}
+}
+
+func TestPick(t *testing.T) {
+	bk, err := Load(bytes.NewReader(makeBook(testBook)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := dragontoothmg.ParseFen(dragontoothmg.Startpos)
+	r := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		m, ok := bk.Pick(&b, r)
+		if !ok {
+			t.Fatal("No book move for the starting position")
+		}
+		counts[m.String()]++
+	}
+	// e2e4 has three times the weight of d2d4
+	if len(counts) != 2 || counts["e2e4"] < 2800 || counts["e2e4"] > 3200 {
+		t.Error("Book moves were not picked by weight:", counts)
+	}
+
+	b = dragontoothmg.ParseFen("k7/8/8/8/8/8/8/K7 w - - 0 1")
+	if _, ok := bk.Pick(&b, r); ok {
+		t.Error("Picked a move for a position that is not in the book")
+	}
+}
+
+func TestInvalidBook(t *testing.T) {
+	if _, err := New(make([]byte, 20)); err == nil {
+		t.Error("A book with a partial entry was accepted")
+	}
+}
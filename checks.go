@@ -0,0 +1,136 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+// Generates all legal moves that give check, including discovered checks,
+// checks by promotion, castling checks and en passant discoveries.
+// Only moves that land on a square attacking the opponent king, or that move a
+// discovered-check candidate off its ray, are generated. Promotions, castling and
+// en passant captures are then tested individually with GivesCheck.
+func (b *Board) GenerateLegalChecks() []Move {
+	var ourPieces, oppPieces *Bitboards
+	var promotionRank uint64
+	if b.Wtomove {
+		ourPieces, oppPieces = &(b.White), &(b.Black)
+		promotionRank = onlyRank[7]
+	} else {
+		ourPieces, oppPieces = &(b.Black), &(b.White)
+		promotionRank = onlyRank[0]
+	}
+	oppKing := uint8(bits.TrailingZeros64(oppPieces.Kings))
+	occupancy := b.White.All | b.Black.All
+
+	// The squares from which each piece type attacks the opponent king
+	pawnChecks := PawnAttacks(Square(oppKing), !b.Wtomove) | promotionRank
+	knightChecks := knightMasks[oppKing]
+	bishopChecks := CalculateBishopMoveBitboard(oppKing, occupancy)
+	rookChecks := CalculateRookMoveBitboard(oppKing, occupancy)
+
+	var buf [kMaxMoveListLength]Move
+	moves := buf[:0]
+	// Discovered-check candidates also check by leaving the ray of their slider
+	discovered := b.DiscoveredChecks()
+	var candidates uint64
+	for _, pin := range discovered {
+		piece := uint64(1) << pin.Pinned
+		candidates |= piece
+		// A piece on the ray can't check directly from elsewhere on it, since the
+		// squares between it and the king are empty
+		offRay := ^pin.Ray
+		moves = b.pawnPushes(moves, piece, offRay)
+		moves = b.pawnCaptures(moves, piece, offRay)
+		moves = b.knightMoves(moves, piece, offRay)
+		moves = b.bishopMoves(moves, piece, offRay)
+		moves = b.rookMoves(moves, piece, offRay)
+		moves = b.queenMoves(moves, piece, offRay)
+		if piece&ourPieces.Kings != 0 {
+			moves = genMovesFromTargets(moves, Square(pin.Pinned), kingMasks[pin.Pinned]&^ourPieces.All&offRay)
+		}
+	}
+	others := ^candidates
+	moves = b.pawnPushes(moves, others, pawnChecks)
+	moves = b.pawnCaptures(moves, others, pawnChecks)
+	moves = b.knightMoves(moves, others, knightChecks)
+	moves = b.bishopMoves(moves, others, bishopChecks)
+	moves = b.rookMoves(moves, others, rookChecks)
+	moves = b.queenMoves(moves, others, bishopChecks|rookChecks)
+	if !b.OurKingInCheck() {
+		moves = b.castlingMoves(moves, everything)
+	}
+
+	var checks []Move
+	for _, m := range moves {
+		if !b.IsLegal(m) {
+			continue
+		}
+		// Promotions, en passant captures and castling moves are generated
+		// whether or not they give check
+		enpassant := m.To() == b.enpassant && b.enpassant != 0 &&
+			ourPieces.Pawns&(uint64(1)<<m.From()) != 0
+		if (m.Promote() != Nothing || enpassant || b.isCastling(m)) && !b.GivesCheck(m) {
+			continue
+		}
+		checks = append(checks, m)
+	}
+	return checks
+}
+
+// Determines whether a legal move gives check, without applying it.
+// The opponent king's attack rays are recomputed with the occupancy after the move,
+// so that discovered checks (including through an en passant capture or
+// the rook of a castling move) are found.
+func (b *Board) GivesCheck(m Move) bool {
+	var ourPieces, oppPieces Bitboards
+	var epDelta int8
+	if b.Wtomove {
+		ourPieces, oppPieces = b.White, b.Black
+		epDelta = -8
+	} else {
+		ourPieces, oppPieces = b.Black, b.White
+		epDelta = 8
+	}
+	fromBitboard := uint64(1) << m.From()
+	toBitboard := uint64(1) << m.To()
+	oppKing := uint8(bits.TrailingZeros64(oppPieces.Kings))
+	occupancy := (b.White.All | b.Black.All) &^ fromBitboard
+
+	// Move our piece, then handle the special moves
+	if b.isCastling(m) {
+		kingTo, rookFrom, rookTo := b.castlingSquares(m, b.Wtomove)
+		ourPieces.Rooks ^= (uint64(1) << rookFrom) ^ (uint64(1) << rookTo)
+		occupancy &^= uint64(1) << rookFrom
+		occupancy |= (uint64(1) << kingTo) | (uint64(1) << rookTo)
+	} else {
+		pieceType, pieceTypeBitboard := determinePieceType(&ourPieces, fromBitboard)
+		*pieceTypeBitboard &^= fromBitboard
+		if m.Promote() != Nothing {
+			*pieceBitboard(&ourPieces, m.Promote()) |= toBitboard
+		} else {
+			*pieceTypeBitboard |= toBitboard
+		}
+		occupancy |= toBitboard
+		if pieceType == Pawn && m.To() == b.enpassant && b.enpassant != 0 {
+			occupancy &^= uint64(1) << uint8(int8(b.enpassant)+epDelta)
+		}
+	}
+
+	// Look for our attackers along the opponent king's rays
+	if knightMasks[oppKing]&ourPieces.Knights != 0 {
+		return true
+	}
+	if CalculateRookMoveBitboard(oppKing, occupancy)&(ourPieces.Rooks|ourPieces.Queens) != 0 {
+		return true
+	}
+	if CalculateBishopMoveBitboard(oppKing, occupancy)&(ourPieces.Bishops|ourPieces.Queens) != 0 {
+		return true
+	}
+	var pawnAttacks uint64
+	if b.Wtomove {
+		pawnAttacks = (ourPieces.Pawns << 9 & ^(onlyFile[0])) | (ourPieces.Pawns << 7 & ^(onlyFile[7]))
+	} else {
+		pawnAttacks = (ourPieces.Pawns >> 7 & ^(onlyFile[0])) | (ourPieces.Pawns >> 9 & ^(onlyFile[7]))
+	}
+	return pawnAttacks&(uint64(1)<<oppKing) != 0
+}
@@ -0,0 +1,83 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestGenerateLegalChecks(t *testing.T) {
+	positions := map[string][]string{
+		// castling gives check along the f-file
+		"5k2/8/8/8/8/8/8/4K2R w K - 0 1": {"e1g1", "h1h8", "h1f1"},
+		// en passant capture discovers a rook check along the fifth rank
+		"8/8/8/1k1pP2R/8/8/8/4K3 w - d6 0 1": {"e5d6"},
+		// checks by promotion, including underpromotion
+		"3k4/1P6/8/8/8/8/8/4K3 w - - 0 1": {"b7b8q", "b7b8r"},
+		"8/1P1k4/8/8/8/8/8/4K3 w - - 0 1": {"b7b8n"},
+		// the king discovers a check by stepping off the file
+		"4k3/8/8/8/8/8/4K3/4R3 w - - 0 1": {"e2d1", "e2d2", "e2d3", "e2f1", "e2f2", "e2f3"},
+		// a pinned piece can't give check
+		"4k3/8/8/8/8/8/3N4/2bK4 w - - 0 1": {},
+	}
+	for fen, expected := range positions {
+		b := ParseFen(fen)
+		checks := b.GenerateLegalChecks()
+		found := make(map[string]bool)
+		var checkStrings []string
+		for _, m := range checks {
+			found[m.String()] = true
+			checkStrings = append(checkStrings, m.String())
+		}
+		for _, m := range expected {
+			if !found[m] {
+				t.Error("Missing check", m, "in", fen)
+			}
+		}
+		if len(checks) != len(expected) {
+			t.Error("Found checks", checkStrings, "in", fen, "; expected", expected)
+		}
+	}
+
+	// Compare against applying each move and testing for check
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",
+		"8/8/8/1k1pP2R/8/8/8/4K3 w - d6 0 1",
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+	}
+	for _, fen := range fens {
+		checkGivesCheck(ParseFen(fen), 3, t)
+	}
+}
+
+func checkGivesCheck(b Board, depth int, t *testing.T) {
+	if depth == 0 {
+		return
+	}
+	expected := 0
+	for _, m := range b.GenerateLegalMoves() {
+		givesCheck := b.GivesCheck(m)
+		unapply := b.Apply(m)
+		if givesCheck != b.OurKingInCheck() {
+			t.Error("GivesCheck was", givesCheck, "for", &m, "leading to", b.ToFen())
+		}
+		if b.OurKingInCheck() {
+			expected++
+		}
+		checkGivesCheck(b, depth-1, t)
+		unapply()
+	}
+	checks := b.GenerateLegalChecks()
+	found := make(map[Move]bool)
+	for _, m := range checks {
+		if found[m] || !b.GivesCheck(m) {
+			t.Error("Found duplicate or non-checking move", &m, "in", b.ToFen())
+		}
+		found[m] = true
+	}
+	if len(checks) != expected {
+		t.Error("Found", len(checks), "checks in", b.ToFen(), "; expected", expected)
+	}
+}
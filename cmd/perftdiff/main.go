@@ -0,0 +1,285 @@
+// Command perftdiff finds move generator bugs by comparing perft divide counts
+// against a reference, and bisecting to the first position whose legal move list
+// differs from the reference's.
+//
+// The reference is either a UCI engine that supports "go perft", run as a
+// subprocess:
+//
+//	perftdiff -engine stockfish -depth 5 -fen "<fen>"
+//
+// An EPD file of positions with perft counts (e.g. ";D1 20 ;D2 400"), such as
+// perftsuite.epd, can also be checked:
+//
+//	perftdiff -epd perftsuite.epd [-engine stockfish]
+//
+// The file only has total counts, not move lists, so each mismatched position
+// is reported, and then bisected only if an engine is given.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/epd"
+)
+
+// A source of reference perft counts.
+type reference interface {
+	// Returns the reference count for each move in the position, by move string.
+	divide(b *dragontoothmg.Board, depth int) (map[string]int64, error)
+}
+
+func main() {
+	fen := flag.String("fen", dragontoothmg.Startpos, "the position to check")
+	depth := flag.Int("depth", 4, "the perft depth")
+	engine := flag.String("engine", "", "the command line of a reference UCI engine")
+	epdFile := flag.String("epd", "", "an EPD file of reference perft counts")
+	chess960 := flag.Bool("chess960", false, "use Chess960 castling")
+	flag.Parse()
+
+	var ref reference
+	if *engine != "" {
+		e, err := startEngine(*engine, *chess960)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer e.close()
+		ref = e
+	}
+	if *epdFile == "" {
+		if ref == nil {
+			log.Fatal("perftdiff: one of -engine or -epd is required")
+		}
+		parse := dragontoothmg.ParseFenStrict
+		if *chess960 {
+			parse = dragontoothmg.ParseChess960Fen
+		}
+		b, err := parse(*fen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := bisect(ref, &b, *depth); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(*epdFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	positions, err := readEPD(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mismatched := 0
+	for _, p := range positions {
+		b := p.board
+		b.Chess960 = b.Chess960 || *chess960
+		var depths []int
+		for d := range p.counts {
+			depths = append(depths, d)
+		}
+		sort.Ints(depths)
+		for _, d := range depths {
+			if result := dragontoothmg.Perft(&b, d); result != p.counts[d] {
+				fmt.Printf("line %d: %v\n  depth %d: %d nodes; expected %d\n",
+					p.line, b.ToFen(), d, result, p.counts[d])
+				if ref != nil {
+					if err := bisect(ref, &b, d); err != nil {
+						log.Fatal(err)
+					}
+				}
+				mismatched++
+				break
+			}
+		}
+	}
+	fmt.Printf("%d of %d positions mismatched\n", mismatched, len(positions))
+	if mismatched > 0 && ref == nil {
+		fmt.Println("Use -engine to bisect the mismatches")
+	}
+}
+
+// Compares the divide of a position with the reference, and recurses into the
+// first move whose count differs, until the move lists themselves differ.
+func bisect(ref reference, b *dragontoothmg.Board, depth int) error {
+	ours := make(map[string]int64)
+	for move, count := range dragontoothmg.DivideMap(b, depth) {
+		ours[move.String()] = count
+	}
+	theirs, err := ref.divide(b, depth)
+	if err != nil {
+		return err
+	}
+	var moves []string
+	for move := range ours {
+		moves = append(moves, move)
+	}
+	for move := range theirs {
+		if _, ok := ours[move]; !ok {
+			moves = append(moves, move)
+		}
+	}
+	sort.Strings(moves)
+
+	var listDiffers bool
+	var firstDiff string
+	for _, move := range moves {
+		count, ok := ours[move]
+		refCount, refOk := theirs[move]
+		switch {
+		case !ok:
+			fmt.Printf("  %-6s not generated (reference: %d)\n", move, refCount)
+			listDiffers = true
+		case !refOk:
+			fmt.Printf("  %-6s not in the reference (ours: %d)\n", move, count)
+			listDiffers = true
+		case count != refCount:
+			fmt.Printf("  %-6s %d; expected %d\n", move, count, refCount)
+			if firstDiff == "" {
+				firstDiff = move
+			}
+		}
+	}
+	if listDiffers {
+		fmt.Printf("The move list differs in %v\n", b.ToFen())
+		return nil
+	}
+	if firstDiff == "" {
+		fmt.Printf("The divides match at depth %d in %v\n", depth, b.ToFen())
+		return nil
+	}
+	move, err := dragontoothmg.ParseMove(firstDiff)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("After %v, at depth %d:\n", firstDiff, depth-1)
+	unapply := b.Apply(move)
+	defer unapply()
+	return bisect(ref, b, depth-1)
+}
+
+// A reference UCI engine, running as a subprocess.
+type engine struct {
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	out    *bufio.Scanner
+	prefix string // the command that sets up Chess960, if needed
+}
+
+// Starts a UCI engine, and waits for it to be ready.
+func startEngine(commandLine string, chess960 bool) (*engine, error) {
+	args := strings.Fields(commandLine)
+	cmd := exec.Command(args[0], args[1:]...)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	e := &engine{cmd: cmd, in: in, out: bufio.NewScanner(out)}
+	if chess960 {
+		e.prefix = "setoption name UCI_Chess960 value true\n"
+	}
+	if _, err := fmt.Fprint(e.in, "uci\n"); err != nil {
+		return nil, err
+	}
+	if err := e.waitFor("uciok"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reads lines from the engine until one starts with the given prefix.
+func (e *engine) waitFor(prefix string) error {
+	for e.out.Scan() {
+		if strings.HasPrefix(e.out.Text(), prefix) {
+			return nil
+		}
+	}
+	if err := e.out.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("perftdiff: engine exited before sending %q", prefix)
+}
+
+func (e *engine) divide(b *dragontoothmg.Board, depth int) (map[string]int64, error) {
+	_, err := fmt.Fprintf(e.in, "%vposition fen %v\ngo perft %d\n", e.prefix, b.ToFen(), depth)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]int64)
+	for e.out.Scan() {
+		line := strings.TrimSpace(e.out.Text())
+		if strings.HasPrefix(line, "Nodes searched") {
+			return result, nil
+		}
+		// Divide lines look like "e2e4: 600"
+		fields := strings.Split(line, ": ")
+		if len(fields) != 2 {
+			continue
+		}
+		if _, err := dragontoothmg.ParseMove(fields[0]); err != nil {
+			continue
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		result[fields[0]] = count
+	}
+	if err := e.out.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("perftdiff: engine exited during go perft")
+}
+
+func (e *engine) close() {
+	fmt.Fprint(e.in, "quit\n")
+	e.in.Close()
+	e.cmd.Wait()
+}
+
+// A position of an EPD file, with its perft counts by depth.
+type epdPosition struct {
+	board  dragontoothmg.Board
+	counts map[int]int64
+	line   int
+}
+
+// Reads the positions and perft counts of an EPD file.
+func readEPD(r io.Reader) ([]epdPosition, error) {
+	var positions []epdPosition
+	reader := epd.NewReader(r)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return positions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		counts, err := record.PerftCounts()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", reader.Line(), err)
+		}
+		positions = append(positions, epdPosition{record.Board, counts, reader.Line()})
+	}
+}
@@ -0,0 +1,74 @@
+// Command perftsuite checks the perft counts of an EPD file, such as the
+// standard perftsuite.epd, against the move generator:
+//
+//	perftsuite -maxdepth 5 perftsuite.epd
+//
+// Every D<n> operation up to the maximum depth is checked, and each failure is
+// reported. The exit status is 1 if any count is wrong.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/epd"
+)
+
+func main() {
+	maxDepth := flag.Int("maxdepth", 6, "the deepest perft count to check")
+	workers := flag.Int("workers", 0, "the number of perft goroutines (0 for one per CPU)")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: perftsuite [flags] file.epd")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	var passed, failed int
+	r := epd.NewReader(f)
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		counts, err := record.PerftCounts()
+		if err != nil {
+			log.Fatalf("line %d: %v", r.Line(), err)
+		}
+		var depths []int
+		for depth := range counts {
+			depths = append(depths, depth)
+		}
+		sort.Ints(depths)
+		for _, depth := range depths {
+			if depth > *maxDepth {
+				break
+			}
+			b := record.Board
+			if result := dragontoothmg.PerftParallel(&b, depth, *workers); result != counts[depth] {
+				fmt.Printf("FAIL line %d: %v\n  depth %d: %d nodes; expected %d\n",
+					r.Line(), b.ToFen(), depth, result, counts[depth])
+				failed++
+			} else {
+				passed++
+			}
+		}
+	}
+	fmt.Printf("%d of %d perft counts passed\n", passed, passed+failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
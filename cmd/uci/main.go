@@ -0,0 +1,30 @@
+// Command uci runs a minimal UCI chess engine on standard input and output.
+//
+// Its search plays a random legal move. It is meant for driving the move
+// generator through standard tooling (e.g. "go perft"), and as an example of
+// plugging a search into the uci package.
+package main
+
+import (
+	"log"
+	"math/rand"
+	"os"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/uci"
+)
+
+func randomMove(b dragontoothmg.Board, limits uci.Limits, stop <-chan struct{}, info func(string)) dragontoothmg.Move {
+	moves := b.GenerateLegalMoves()
+	if len(limits.SearchMoves) != 0 {
+		moves = limits.SearchMoves
+	}
+	return moves[rand.Intn(len(moves))]
+}
+
+func main() {
+	engine := uci.Engine{Name: "Dragontooth Movegen", Author: "Dylan D. Hunn", Search: randomMove}
+	if err := engine.Run(os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -18,15 +18,18 @@ func init() {
 	generateZobristConstants()
 }
 
+// Uses a fixed seed, so that hashes are the same in every run of a program.
+// (Polyglot-compatible hashes are available from PolyglotHash.)
 func generateZobristConstants() {
-	whiteToMoveZobristC = rand.Uint64()
+	rng := rand.New(rand.NewSource(1))
+	whiteToMoveZobristC = rng.Uint64()
 	for i := 0; i < 12; i++ {
 		for j := 0; j < 64; j++ {
-			pieceSquareZobristC[i][j] = rand.Uint64()
+			pieceSquareZobristC[i][j] = rng.Uint64()
 		}
 	}
 	for i := 0; i < 4; i++ {
-		castleRightsZobristC[i] = rand.Uint64()
+		castleRightsZobristC[i] = rng.Uint64()
 	}
 }
 
@@ -180,6 +183,9 @@ var whiteToMoveZobristC uint64 // active if white is to move
 
 const kDefaultMoveListLength int = 65
 
+// An upper bound on the number of legal moves in any position.
+const kMaxMoveListLength int = 256
+
 // Bitboard where every bit is active
 var everything uint64 = ^(uint64(0))
 
@@ -0,0 +1,199 @@
+// Package epd reads chess positions in Extended Position Description (EPD),
+// the format of test suites such as perftsuite.epd, WAC and STS.
+//
+// An EPD record is the first four fields of a FEN, followed by operations
+// such as `bm Nf3; id "WAC.001";` or `;D1 20 ;D2 400`. The move counters of a
+// full FEN are also accepted in place of the hmvc and fmvn operations.
+package epd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A Record is a position, with the operations that describe it.
+type Record struct {
+	Board dragontoothmg.Board
+	// The operands of each operation, by opcode. Quoted operands are unquoted.
+	Operations map[string][]string
+}
+
+// Parses a single EPD record.
+func Parse(line string) (*Record, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("epd: expected at least 4 fields, found %d", len(fields))
+	}
+	// Find the operations after the fourth field
+	rest := line
+	for i := 0; i < 4; i++ {
+		rest = strings.TrimSpace(rest)[len(fields[i]):]
+	}
+	operations, err := splitOperations(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Record{Operations: make(map[string][]string)}
+	halfmove, fullmove := "0", "1"
+	for i, op := range operations {
+		// A full FEN's move counters come before the first operation
+		if i == 0 && len(op) >= 2 && isNumber(op[0]) && isNumber(op[1]) {
+			halfmove, fullmove = op[0], op[1]
+			op = op[2:]
+		}
+		if len(op) == 0 {
+			continue
+		}
+		r.Operations[op[0]] = op[1:]
+	}
+	if hmvc := r.Operations["hmvc"]; len(hmvc) == 1 {
+		halfmove = hmvc[0]
+	}
+	if fmvn := r.Operations["fmvn"]; len(fmvn) == 1 {
+		fullmove = fmvn[0]
+	}
+	fen := strings.Join(append(fields[:4:4], halfmove, fullmove), " ")
+	if r.Board, err = dragontoothmg.ParseFenStrict(fen); err != nil {
+		return nil, fmt.Errorf("epd: %v", err)
+	}
+	return r, nil
+}
+
+// Splits the operations of a record at semicolons, and each operation into its
+// opcode and operands.
+func splitOperations(s string) ([][]string, error) {
+	var operations [][]string
+	var op []string
+	var token strings.Builder
+	inToken, quoted := false, false
+	endToken := func() {
+		if inToken {
+			op = append(op, token.String())
+			token.Reset()
+			inToken = false
+		}
+	}
+	for _, c := range s {
+		switch {
+		case quoted && c == '"':
+			quoted = false
+			endToken()
+		case quoted:
+			token.WriteRune(c)
+		case c == '"':
+			endToken()
+			quoted, inToken = true, true
+		case c == ';':
+			endToken()
+			operations = append(operations, op)
+			op = nil
+		case c == ' ' || c == '\t':
+			endToken()
+		default:
+			token.WriteRune(c)
+			inToken = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("epd: unterminated string operand")
+	}
+	endToken()
+	return append(operations, op), nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// Returns the id operation of the record, or "" if there is none.
+func (r *Record) ID() string {
+	return strings.Join(r.Operations["id"], " ")
+}
+
+// Returns the best moves of the bm operation, parsed as SAN.
+func (r *Record) BestMoves() ([]dragontoothmg.Move, error) {
+	return r.moves("bm")
+}
+
+// Returns the moves to avoid of the am operation, parsed as SAN.
+func (r *Record) AvoidMoves() ([]dragontoothmg.Move, error) {
+	return r.moves("am")
+}
+
+func (r *Record) moves(opcode string) ([]dragontoothmg.Move, error) {
+	var moves []dragontoothmg.Move
+	for _, san := range r.Operations[opcode] {
+		b := r.Board
+		m, err := b.ParseSAN(san)
+		if err != nil {
+			return nil, fmt.Errorf("epd: %v operation: %v", opcode, err)
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+// Returns the expected perft counts of the D1, D2, ... operations, by depth.
+func (r *Record) PerftCounts() (map[int]int64, error) {
+	counts := make(map[int]int64)
+	for opcode, operands := range r.Operations {
+		if len(opcode) < 2 || opcode[0] != 'D' || !isNumber(opcode[1:]) {
+			continue
+		}
+		depth, _ := strconv.Atoi(opcode[1:])
+		if len(operands) != 1 {
+			return nil, fmt.Errorf("epd: %v operation: expected 1 operand, found %d", opcode, len(operands))
+		}
+		count, err := strconv.ParseInt(operands[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("epd: %v operation: invalid count %q", opcode, operands[0])
+		}
+		counts[depth] = count
+	}
+	return counts, nil
+}
+
+// A Reader reads EPD records, one per line. Blank lines and lines starting
+// with '#' are skipped.
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// Returns a Reader that reads from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{scanner: bufio.NewScanner(r)}
+}
+
+// Returns the next record, or io.EOF once there are no more.
+func (r *Reader) Next() (*Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		record, err := Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("epd: line %d: %v", r.line, strings.TrimPrefix(err.Error(), "epd: "))
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Returns the line number of the last record read.
+func (r *Reader) Line() int {
+	return r.line
+}
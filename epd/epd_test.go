@@ -0,0 +1,106 @@
+package epd
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse(`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen := r.Board.ToFen(); fen != "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - 0 1" {
+		t.Error("Parsed board was", fen)
+	}
+	if r.ID() != "WAC.001" {
+		t.Error("ID was", r.ID())
+	}
+	moves, err := r.BestMoves()
+	if err != nil || len(moves) != 1 || moves[0].String() != "g3g6" {
+		t.Error("Best moves were", moves, err)
+	}
+
+	r, err = Parse(`r1bqk2r/pp2bppp/2p5/3pP3/P2Q1P2/2N1B3/1PP3PP/R4RK1 b kq - hmvc 3; fmvn 12; am f6 Bc5; c0 "f6=1, Bc5=0";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Board.Halfmoveclock != 3 || r.Board.Fullmoveno != 12 {
+		t.Error("Move counters were", r.Board.Halfmoveclock, r.Board.Fullmoveno)
+	}
+	moves, err = r.AvoidMoves()
+	if err != nil || len(moves) != 2 || moves[0].String() != "f7f6" || moves[1].String() != "e7c5" {
+		t.Error("Moves to avoid were", moves, err)
+	}
+	if c0 := r.Operations["c0"]; !reflect.DeepEqual(c0, []string{"f6=1, Bc5=0"}) {
+		t.Error("Comment operands were", c0)
+	}
+}
+
+func TestPerftCounts(t *testing.T) {
+	r, err := Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 ;D1 20 ;D2 400 ;D3 8902")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fen := r.Board.ToFen(); fen != dragontoothmg.Startpos {
+		t.Error("Parsed board was", fen)
+	}
+	counts, err := r.PerftCounts()
+	if expected := map[int]int64{1: 20, 2: 400, 3: 8902}; err != nil || !reflect.DeepEqual(counts, expected) {
+		t.Error("Perft counts were", counts, err, "; expected", expected)
+	}
+
+	r, err = Parse("4k3/8/8/8/8/8/8/4K2R w K - 0 1 ;D1 15x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.PerftCounts(); err == nil {
+		t.Error("Invalid perft count was accepted")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{
+		"8/8/8/8 w",
+		"8/8/8/8/8/8/8/8 w - -", // no kings
+		`4k3/8/8/8/8/8/8/4K3 w - - id "unterminated`,
+		"4k3/8/8/8/8/8/8/4K3 w - - bm Ke3;", // illegal best move
+	} {
+		r, err := Parse(line)
+		if err == nil {
+			_, err = r.BestMoves()
+		}
+		if err == nil {
+			t.Error("Invalid record was accepted:", line)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	input := `# A comment
+4k3/8/8/8/8/8/8/4K2R w K - 0 1 ;D1 15 ;D2 66
+
+4k3/8/8/8/8/8/8/R3K3 w Q - 0 1 ;D1 16 ;D2 71
+8/8/8/8 w
+`
+	r := NewReader(strings.NewReader(input))
+	for _, expected := range []int64{15, 16} {
+		record, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts, _ := record.PerftCounts(); counts[1] != expected {
+			t.Error("Depth 1 count was", counts[1], "; expected", expected)
+		}
+	}
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Error("Expected an error on line 5, got", err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Error("Expected EOF, got", err)
+	}
+}
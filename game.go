@@ -0,0 +1,93 @@
+package dragontoothmg
+
+// A Game wraps a Board with the history of the moves applied to it, so that
+// moves can be taken back and repetitions detected.
+type Game struct {
+	Board  Board
+	moves  []Move
+	undos  []Undo
+	hashes []uint64 // the hash of the position before each move
+}
+
+// Creates a game starting from the given position.
+func NewGame(b Board) *Game {
+	return &Game{Board: b}
+}
+
+// Applies a move to the game's board, and records it in the history.
+// This function assumes that the given move is legal, just like Board.Apply().
+func (g *Game) Push(m Move) {
+	g.hashes = append(g.hashes, repetitionHash(&g.Board))
+	g.undos = append(g.undos, g.Board.MakeMove(m))
+	g.moves = append(g.moves, m)
+}
+
+// Takes back the most recently pushed move, and returns it.
+// If no moves have been pushed, the board is unchanged and 0 is returned.
+func (g *Game) Pop() Move {
+	n := len(g.moves)
+	if n == 0 {
+		return 0
+	}
+	m := g.moves[n-1]
+	g.Board.UnmakeMove(m, g.undos[n-1])
+	g.moves, g.undos, g.hashes = g.moves[:n-1], g.undos[:n-1], g.hashes[:n-1]
+	return m
+}
+
+// Returns the moves pushed so far, in order. The slice must not be modified.
+func (g *Game) Moves() []Move {
+	return g.moves
+}
+
+// Determines whether the current position has occurred at least n times
+// (including the current occurrence) since the game started.
+// Only positions with the same side to move since the last capture or pawn
+// move are considered, since no earlier position can be repeated.
+// As in the FIDE rules, the en passant square set by a double pawn push only
+// distinguishes positions if an en passant capture is legal.
+func (g *Game) IsRepetition(n int) bool {
+	hash := repetitionHash(&g.Board)
+	count := 1
+	oldest := len(g.hashes) - int(g.Board.Halfmoveclock)
+	for i := len(g.hashes) - 2; i >= 0 && i >= oldest; i -= 2 {
+		if g.hashes[i] == hash {
+			count++
+			if count >= n {
+				return true
+			}
+		}
+	}
+	return count >= n
+}
+
+// Determines whether the current position has occurred three times, so
+// that either player may claim a draw.
+func (g *Game) IsThreefold() bool {
+	return g.IsRepetition(3)
+}
+
+// Determines whether the current position has occurred five times, so
+// that the game is automatically drawn.
+func (g *Game) IsFivefold() bool {
+	return g.IsRepetition(5)
+}
+
+// Returns the hash of a position for detecting repetitions, which ignores the
+// en passant square unless an en passant capture is legal.
+func repetitionHash(b *Board) uint64 {
+	hash := b.Hash()
+	if b.enpassant == 0 {
+		return hash
+	}
+	pawns := b.White.Pawns
+	if !b.Wtomove {
+		pawns = b.Black.Pawns
+	}
+	for _, m := range b.GenerateLegalCaptures() {
+		if m.To() == b.enpassant && pawns&(uint64(1)<<m.From()) != 0 {
+			return hash
+		}
+	}
+	return hash ^ uint64(b.enpassant)
+}
@@ -0,0 +1,83 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestGameRepetition(t *testing.T) {
+	g := NewGame(ParseFen(Startpos))
+	shuffle := []string{"g1f3", "g8f6", "f3g1", "f6g8"}
+	for i := 1; i <= 4; i++ {
+		for _, m := range shuffle {
+			g.Push(parseMove(m))
+		}
+		// The starting position has now occurred i+1 times
+		if g.IsRepetition(i+1) != true || g.IsRepetition(i+2) != false {
+			t.Error("Starting position should have occurred exactly", i+1, "times")
+		}
+		if g.IsThreefold() != (i+1 >= 3) || g.IsFivefold() != (i+1 >= 5) {
+			t.Error("Wrong threefold or fivefold result after", i+1, "occurrences")
+		}
+	}
+	// Take back a move, leaving a position that has occurred four times
+	if m := g.Pop(); m != parseMove("f6g8") {
+		t.Error("Pop returned", &m, "; expected f6g8")
+	}
+	if !g.IsRepetition(4) || g.IsFivefold() {
+		t.Error("Position after Pop should have occurred four times")
+	}
+	g.Push(parseMove("f6g8"))
+	if !g.IsFivefold() {
+		t.Error("Position after Push should have occurred five times")
+	}
+
+	// Positions before a pawn move can't be repeated
+	g.Push(parseMove("e2e3"))
+	for _, m := range []string{"g8f6", "g1f3", "f6g8", "f3g1"} {
+		g.Push(parseMove(m))
+	}
+	if !g.IsRepetition(2) || g.IsRepetition(3) {
+		t.Error("Positions before a pawn move should not count as repetitions")
+	}
+	if len(g.Moves()) != 21 {
+		t.Error("Game history has", len(g.Moves()), "moves; expected 21")
+	}
+}
+
+// The en passant square after 1.e4 doesn't make the position different, since
+// no en passant capture is possible, but one that can be captured does.
+func TestGameRepetitionEnPassant(t *testing.T) {
+	g := NewGame(ParseFen(Startpos))
+	for _, m := range []string{"e2e4", "g8f6", "g1f3", "f6g8", "f3g1", "g8f6", "g1f3", "f6g8", "f3g1"} {
+		g.Push(parseMove(m))
+	}
+	if !g.IsThreefold() {
+		t.Error("1.e4 Nf6 2.Nf3 Ng8 3.Ng1 Nf6 4.Nf3 Ng8 5.Ng1 should be a threefold repetition")
+	}
+
+	g = NewGame(ParseFen("4k3/8/8/8/3p4/8/4P3/4K3 w - - 0 1"))
+	for _, m := range []string{"e2e4", "e8d8", "e1d1", "d8e8", "d1e1", "e8d8", "e1d1", "d8e8", "d1e1"} {
+		g.Push(parseMove(m))
+	}
+	if !g.IsRepetition(2) || g.IsThreefold() {
+		t.Error("The position with a legal en passant capture should not count as a repetition")
+	}
+}
+
+func TestGamePop(t *testing.T) {
+	g := NewGame(ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"))
+	var fens []string
+	for _, m := range []string{"e1g1", "a6e2", "c3e2", "e8c8", "d5e6"} {
+		fens = append(fens, g.Board.ToFen())
+		g.Push(parseMove(m))
+	}
+	for i := len(fens) - 1; i >= 0; i-- {
+		g.Pop()
+		if g.Board.ToFen() != fens[i] {
+			t.Error("Pop produced", g.Board.ToFen(), "; expected", fens[i])
+		}
+	}
+	if m := g.Pop(); m != 0 || g.Board.ToFen() != fens[0] {
+		t.Error("Pop with no moves should have no effect")
+	}
+}
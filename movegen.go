@@ -13,60 +13,138 @@ import (
 
 // The main API entrypoint. Generates all legal moves for a given board.
 func (b *Board) GenerateLegalMoves() []Move {
-	moves := make([]Move, 0, kDefaultMoveListLength)
+	return b.GenerateLegalMovesInto(make([]Move, 0, kDefaultMoveListLength))
+}
+
+// Generates all legal moves for a given board, appending them to buf[:0].
+// Reusing a buffer of capacity 256 (such as a stack-allocated array per ply)
+// avoids allocation, since no position has more legal moves than that.
+func (b *Board) GenerateLegalMovesInto(buf []Move) []Move {
+	return b.generateLegalMoves(buf[:0], true, true)
+}
+
+// Generates all legal captures and promotions (including non-capturing promotions).
+// En passant captures are included.
+func (b *Board) GenerateLegalCaptures() []Move {
+	return b.generateLegalMoves(make([]Move, 0, kDefaultMoveListLength), true, false)
+}
+
+// Generates all legal moves that are neither captures nor promotions.
+// Castling moves are included.
+func (b *Board) GenerateLegalQuiets() []Move {
+	return b.generateLegalMoves(make([]Move, 0, kDefaultMoveListLength), false, true)
+}
+
+// A staged legal move generator, which yields captures and promotions before
+// generating any quiet moves. The board must not be changed between calls to Next
+// (moves may be applied and unapplied in between, as in a search).
+type MoveIterator struct {
+	board *Board
+	stage int // 0: not started, 1: yielding captures, 2: yielding quiets
+	next  int
+	count int
+	buf   [kMaxMoveListLength]Move
+}
+
+// Returns a staged iterator over the legal moves of the board.
+func (b *Board) NewMoveIterator() MoveIterator {
+	return MoveIterator{board: b}
+}
+
+// Returns the next legal move, and false once all moves have been yielded.
+func (it *MoveIterator) Next() (Move, bool) {
+	for it.next >= it.count {
+		switch it.stage {
+		case 0:
+			it.count = len(it.board.generateLegalMoves(it.buf[:0], true, false))
+		case 1:
+			it.count = len(it.board.generateLegalMoves(it.buf[:0], false, true))
+		default:
+			return 0, false
+		}
+		it.stage++
+		it.next = 0
+	}
+	m := it.buf[it.next]
+	it.next++
+	return m, true
+}
+
+// Appends legal moves to the list. Captures (including all promotions) and
+// quiet moves can be independently included or excluded, by restricting
+// the destination squares passed to each piece's move generator.
+func (b *Board) generateLegalMoves(moves []Move, captures bool, quiets bool) []Move {
 	// First, see if we are currently in check. If we are, invoke a special check-
 	// evasion move generator.
 	var kingLocation uint8
-	var ourPiecesPtr *Bitboards
+	var ourPiecesPtr, oppPiecesPtr *Bitboards
+	var promotionRank uint64
 	if b.Wtomove { // assumes only one king
 		kingLocation = uint8(bits.TrailingZeros64(b.White.Kings))
 		ourPiecesPtr = &(b.White)
+		oppPiecesPtr = &(b.Black)
+		promotionRank = onlyRank[7]
 	} else {
 		kingLocation = uint8(bits.TrailingZeros64(b.Black.Kings))
 		ourPiecesPtr = &(b.Black)
+		oppPiecesPtr = &(b.White)
+		promotionRank = onlyRank[0]
+	}
+	// Pawn pushes are only captures if they promote.
+	targetMask, pushMask := everything, everything
+	if !captures {
+		targetMask, pushMask = ^(oppPiecesPtr.All), ^promotionRank
+	} else if !quiets {
+		targetMask, pushMask = oppPiecesPtr.All, promotionRank
 	}
 	kingAttackers, blockerDestinations := b.countAttacks(b.Wtomove, kingLocation, 2)
 	if kingAttackers >= 2 { // Under multiple attack, we must move the king.
-		b.kingPushes(&moves, ourPiecesPtr)
+		moves = b.kingPushes(moves, ourPiecesPtr, targetMask)
 		return moves
 	}
 
 	// Several move types can work in single check, but we must block the check
 	if kingAttackers == 1 {
 		// calculate pinned pieces
-		pinnedPieces := b.generatePinnedMoves(&moves, blockerDestinations)
+		var pinnedPieces uint64
+		moves, pinnedPieces = b.generatePinnedMoves(moves, blockerDestinations&targetMask)
 		nonpinnedPieces := ^pinnedPieces
 		// TODO
-		b.pawnPushes(&moves, nonpinnedPieces, blockerDestinations)
-		b.pawnCaptures(&moves, nonpinnedPieces, blockerDestinations)
-		b.knightMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.rookMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.bishopMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.queenMoves(&moves, nonpinnedPieces, blockerDestinations)
-		b.kingPushes(&moves, ourPiecesPtr)
+		moves = b.pawnPushes(moves, nonpinnedPieces, blockerDestinations&pushMask)
+		if captures {
+			moves = b.pawnCaptures(moves, nonpinnedPieces, blockerDestinations)
+		}
+		moves = b.knightMoves(moves, nonpinnedPieces, blockerDestinations&targetMask)
+		moves = b.rookMoves(moves, nonpinnedPieces, blockerDestinations&targetMask)
+		moves = b.bishopMoves(moves, nonpinnedPieces, blockerDestinations&targetMask)
+		moves = b.queenMoves(moves, nonpinnedPieces, blockerDestinations&targetMask)
+		moves = b.kingPushes(moves, ourPiecesPtr, targetMask)
 		return moves
 	}
 
 	// Then, calculate all the absolutely pinned pieces, and compute their moves.
 	// If we are in check, we can only move to squares that block the check.
-	pinnedPieces := b.generatePinnedMoves(&moves, everything)
+	var pinnedPieces uint64
+	moves, pinnedPieces = b.generatePinnedMoves(moves, targetMask)
 	nonpinnedPieces := ^pinnedPieces
 
 	// Finally, compute ordinary moves, ignoring absolutely pinned pieces on the board.
-	b.pawnPushes(&moves, nonpinnedPieces, everything)
-	b.pawnCaptures(&moves, nonpinnedPieces, everything)
-	b.knightMoves(&moves, nonpinnedPieces, everything)
-	b.rookMoves(&moves, nonpinnedPieces, everything)
-	b.bishopMoves(&moves, nonpinnedPieces, everything)
-	b.queenMoves(&moves, nonpinnedPieces, everything)
-	b.kingMoves(&moves)
+	moves = b.pawnPushes(moves, nonpinnedPieces, pushMask)
+	if captures {
+		moves = b.pawnCaptures(moves, nonpinnedPieces, everything)
+	}
+	moves = b.knightMoves(moves, nonpinnedPieces, targetMask)
+	moves = b.rookMoves(moves, nonpinnedPieces, targetMask)
+	moves = b.bishopMoves(moves, nonpinnedPieces, targetMask)
+	moves = b.queenMoves(moves, nonpinnedPieces, targetMask)
+	moves = b.kingMoves(moves, targetMask)
 	return moves
 }
 
 // Calculate the available moves for absolutely pinned pieces (pinned to the king).
 // We are only allowed to move to squares in allowDest, to block checks.
 // Return a bitboard of all pieces that are pinned.
-func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
+func (b *Board) generatePinnedMoves(moveList []Move, allowDest uint64) ([]Move, uint64) {
 	var ourKingIdx uint8
 	var ourPieces, oppPieces *Bitboards
 	var allPinnedPieces uint64 = 0
@@ -117,7 +195,7 @@ func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
 					pawnTargets |= (1 << uint8(int(pinnedPieceIdx)+16*pawnPushDirection)) & ^allPieces & doublePushRank
 				}
 				pawnTargets &= allowDest // TODO this might be a promotion. Is that possible?
-				genMovesFromTargets(moveList, Square(pinnedPieceIdx), pawnTargets)
+				moveList = genMovesFromTargets(moveList, Square(pinnedPieceIdx), pawnTargets)
 			}
 			continue
 		}
@@ -130,7 +208,7 @@ func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
 		// actually available moves
 		pinnedTargets := pinnedPieceAllMoves & (rookTargets | kingOrthoTargets | (uint64(1) << currRookIdx))
 		pinnedTargets &= allowDest
-		genMovesFromTargets(moveList, Square(pinnedPieceIdx), pinnedTargets)
+		moveList = genMovesFromTargets(moveList, Square(pinnedPieceIdx), pinnedTargets)
 	}
 
 	// Calculate king moves as if it was a bishop.
@@ -164,15 +242,27 @@ func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
 						for i := Piece(Knight); i <= Queen; i++ {
 							var move Move
 							move.Setfrom(Square(pinnedPieceIdx)).Setto(Square(currBishopIdx)).Setpromote(i)
-							*moveList = append(*moveList, move)
+							moveList = append(moveList, move)
 						}
 					} else { // no promotion
 						var move Move
 						move.Setfrom(Square(pinnedPieceIdx)).Setto(Square(currBishopIdx))
-						*moveList = append(*moveList, move)
+						moveList = append(moveList, move)
 					}
 				}
 			}
+			// It may also capture en passant, if the target square is on the pin ray.
+			// The captured pawn's square must be in allowDest.
+			if b.enpassant != 0 && (uint64(1)<<b.enpassant)&(bishopTargets|kingDiagTargets) != 0 &&
+				int(b.enpassant/8) == int(pinnedPieceIdx/8)+pawnPushDirection &&
+				(b.enpassant%8 == pinnedPieceIdx%8+1 || b.enpassant%8+1 == pinnedPieceIdx%8) &&
+				(uint64(1)<<uint8(int(b.enpassant)-8*pawnPushDirection))&allowDest != 0 {
+				var move Move
+				move.Setfrom(Square(pinnedPieceIdx)).Setto(Square(b.enpassant))
+				if b.enpassantIsLegal(move) {
+					moveList = append(moveList, move)
+				}
+			}
 			continue
 		}
 		// If it's not a bishop or queen, it can't move
@@ -184,14 +274,14 @@ func (b *Board) generatePinnedMoves(moveList *[]Move, allowDest uint64) uint64 {
 		// actually available moves
 		pinnedTargets := pinnedPieceAllMoves & (bishopTargets | kingDiagTargets | (uint64(1) << currBishopIdx))
 		pinnedTargets &= allowDest
-		genMovesFromTargets(moveList, Square(pinnedPieceIdx), pinnedTargets)
+		moveList = genMovesFromTargets(moveList, Square(pinnedPieceIdx), pinnedTargets)
 	}
-	return allPinnedPieces
+	return moveList, allPinnedPieces
 }
 
 // Generate moves involving advancing pawns.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) pawnPushes(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) pawnPushes(moveList []Move, nonpinned uint64, allowDest uint64) []Move {
 	targets, doubleTargets := b.pawnPushBitboards(nonpinned)
 	targets, doubleTargets = targets&allowDest, doubleTargets&allowDest
 	oneRankBack := 8
@@ -213,10 +303,10 @@ func (b *Board) pawnPushes(moveList *[]Move, nonpinned uint64, allowDest uint64)
 		if canPromote {
 			for i := Piece(Knight); i <= Queen; i++ {
 				move.Setpromote(i)
-				*moveList = append(*moveList, move)
+				moveList = append(moveList, move)
 			}
 		} else {
-			*moveList = append(*moveList, move)
+			moveList = append(moveList, move)
 		}
 	}
 	// push some pawns by two squares
@@ -225,8 +315,9 @@ func (b *Board) pawnPushes(moveList *[]Move, nonpinned uint64, allowDest uint64)
 		doubleTargets &= doubleTargets - 1 // unset the lowest active bit
 		var move Move
 		move.Setfrom(Square(doubleTarget + 2*oneRankBack)).Setto(Square(doubleTarget))
-		*moveList = append(*moveList, move)
+		moveList = append(moveList, move)
 	}
+	return moveList
 }
 
 // A helper function that produces bitboards of valid pawn push locations.
@@ -246,7 +337,7 @@ func (b *Board) pawnPushBitboards(nonpinned uint64) (targets uint64, doubleTarge
 
 // A function that computes available pawn captures.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) pawnCaptures(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) pawnCaptures(moveList []Move, nonpinned uint64, allowDest uint64) []Move {
 	east, west := b.pawnCaptureBitboards(nonpinned)
 	if b.enpassant > 0 { // always allow us to try en-passant captures
 		allowDest = allowDest | 1<<b.enpassant
@@ -270,47 +361,44 @@ func (b *Board) pawnCaptures(moveList *[]Move, nonpinned uint64, allowDest uint6
 				move.Setfrom(Square(target + (9 - (dir * 2))))
 				canPromote = target <= 7
 			}
-			if uint8(target) == b.enpassant && b.enpassant != 0 {
-				// Apply, check actual legality, then unapply
-				// Warning: not thread safe
-				var ourPieces, oppPieces *Bitboards
-				var enpassantEnemy uint8
-				if b.Wtomove {
-					enpassantEnemy = uint8(move.To()) - 8
-					ourPieces = &(b.White)
-					oppPieces = &(b.Black)
-				} else {
-					enpassantEnemy = uint8(move.To()) + 8
-					ourPieces = &(b.Black)
-					oppPieces = &(b.White)
-				}
-				ourPieces.Pawns &= ^(uint64(1) << move.From())
-				ourPieces.All &= ^(uint64(1) << move.From())
-				ourPieces.Pawns |= (uint64(1) << move.To())
-				ourPieces.All |= (uint64(1) << move.To())
-				oppPieces.Pawns &= ^(uint64(1) << enpassantEnemy)
-				oppPieces.All &= ^(uint64(1) << enpassantEnemy)
-				kingInCheck := b.OurKingInCheck()
-				ourPieces.Pawns |= (uint64(1) << move.From())
-				ourPieces.All |= (uint64(1) << move.From())
-				ourPieces.Pawns &= ^(uint64(1) << move.To())
-				ourPieces.All &= ^(uint64(1) << move.To())
-				oppPieces.Pawns |= (uint64(1) << enpassantEnemy)
-				oppPieces.All |= (uint64(1) << enpassantEnemy)
-				if kingInCheck {
-					continue
-				}
+			if uint8(target) == b.enpassant && b.enpassant != 0 && !b.enpassantIsLegal(move) {
+				continue
 			}
 			if canPromote {
 				for i := Piece(Knight); i <= Queen; i++ {
 					move.Setpromote(i)
-					*moveList = append(*moveList, move)
+					moveList = append(moveList, move)
 				}
 				continue
 			}
-			*moveList = append(*moveList, move)
+			moveList = append(moveList, move)
 		}
 	}
+	return moveList
+}
+
+// Determines whether an en passant capture leaves our king safe, by playing
+// the capture on a copy of the board.
+func (b *Board) enpassantIsLegal(move Move) bool {
+	after := *b
+	var ourPieces, oppPieces *Bitboards
+	var enpassantEnemy uint8
+	if b.Wtomove {
+		enpassantEnemy = uint8(move.To()) - 8
+		ourPieces = &(after.White)
+		oppPieces = &(after.Black)
+	} else {
+		enpassantEnemy = uint8(move.To()) + 8
+		ourPieces = &(after.Black)
+		oppPieces = &(after.White)
+	}
+	ourPieces.Pawns &= ^(uint64(1) << move.From())
+	ourPieces.All &= ^(uint64(1) << move.From())
+	ourPieces.Pawns |= (uint64(1) << move.To())
+	ourPieces.All |= (uint64(1) << move.To())
+	oppPieces.Pawns &= ^(uint64(1) << enpassantEnemy)
+	oppPieces.All &= ^(uint64(1) << enpassantEnemy)
+	return !after.OurKingInCheck()
 }
 
 // A helper than generates bitboards for available pawn captures.
@@ -339,7 +427,7 @@ func (b *Board) pawnCaptureBitboards(nonpinned uint64) (east uint64, west uint64
 
 // Generate all knight moves.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) knightMoves(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) knightMoves(moveList []Move, nonpinned uint64, allowDest uint64) []Move {
 	var ourKnights, noFriendlyPieces uint64
 	if b.Wtomove {
 		ourKnights = b.White.Knights & nonpinned
@@ -352,88 +440,105 @@ func (b *Board) knightMoves(moveList *[]Move, nonpinned uint64, allowDest uint64
 		currentKnight := bits.TrailingZeros64(ourKnights)
 		ourKnights &= ourKnights - 1
 		targets := knightMasks[currentKnight] & noFriendlyPieces & allowDest
-		genMovesFromTargets(moveList, Square(currentKnight), targets)
+		moveList = genMovesFromTargets(moveList, Square(currentKnight), targets)
 	}
+	return moveList
 }
 
-// Computes king moves without castling.
-func (b *Board) kingPushes(moveList *[]Move, ptrToOurBitboards *Bitboards) {
+// Computes king moves without castling. Only squares in allowDest can be moved to.
+func (b *Board) kingPushes(moveList []Move, ptrToOurBitboards *Bitboards, allowDest uint64) []Move {
 	ourKingLocation := uint8(bits.TrailingZeros64(ptrToOurBitboards.Kings))
 	noFriendlyPieces := ^(ptrToOurBitboards.All)
 
-	// TODO(dylhunn): Modifying the board is NOT thread-safe.
-	// We only do this to avoid the king danger problem, aka moving away from a
-	// checking slider.
-	oldKings := ptrToOurBitboards.Kings
-	ptrToOurBitboards.Kings = 0
-	ptrToOurBitboards.All &= ^(uint64(1) << ourKingLocation)
-	targets := kingMasks[ourKingLocation] & noFriendlyPieces
-	for targets != 0 {
-		target := bits.TrailingZeros64(targets)
-		targets &= targets - 1
-		if b.UnderDirectAttack(b.Wtomove, uint8(target)) {
-			continue
-		}
-		var move Move
-		move.Setfrom(Square(ourKingLocation)).Setto(Square(target))
-		*moveList = append(*moveList, move)
-	}
-
-	ptrToOurBitboards.Kings = oldKings
-	ptrToOurBitboards.All |= (1 << ourKingLocation)
+	// Compute king-danger squares with our king removed from the occupancy, so that
+	// the king can't move away from a checking slider along the line of attack.
+	allPieces := (b.White.All | b.Black.All) & ^(uint64(1) << ourKingLocation)
+	danger := b.attackedSquares(b.Wtomove, allPieces)
+	targets := kingMasks[ourKingLocation] & noFriendlyPieces & ^danger & allowDest
+	moveList = genMovesFromTargets(moveList, Square(ourKingLocation), targets)
+	return moveList
 }
 
 // Generate all available king moves.
 // First, if castling is possible, verifies the checking prohibitions on castling.
 // Then, outputs castling moves (if any), and king moves.
-// Not thread-safe, since the king is removed from the board to compute
-// king-danger squares.
-func (b *Board) kingMoves(moveList *[]Move) {
-	// castling
-	var ourKingLocation uint8
-	var canCastleQueenside, canCastleKingside bool
+// Only squares in allowDest can be moved to, including the king's castling destination.
+func (b *Board) kingMoves(moveList []Move, allowDest uint64) []Move {
+	moveList = b.castlingMoves(moveList, allowDest)
+	if b.Wtomove {
+		return b.kingPushes(moveList, &(b.White), allowDest)
+	}
+	return b.kingPushes(moveList, &(b.Black), allowDest)
+}
+
+// Generate the legal castling moves, assuming that we are not in check.
+// Only castling moves with the king's destination in allowDest are generated.
+func (b *Board) castlingMoves(moveList []Move, allowDest uint64) []Move {
 	var ptrToOurBitboards *Bitboards
-	allPieces := b.White.All | b.Black.All
+	var rightsShift uint8 // the castlerights bit of our queenside right
+	var backRank uint8
 	if b.Wtomove {
-		ourKingLocation = uint8(bits.TrailingZeros64(b.White.Kings))
 		ptrToOurBitboards = &(b.White)
-		// To castle, we must have rights and a clear path
-		kingsideClear := allPieces&((1<<5)|(1<<6)) == 0
-		queensideClear := allPieces&((1<<3)|(1<<2)|(1<<1)) == 0
-		// skip the king square, since this won't be called while in check
-		canCastleQueenside = b.whiteCanCastleQueenside() &&
-			queensideClear && !b.anyUnderDirectAttack(true, 2, 3)
-		canCastleKingside = b.whiteCanCastleKingside() &&
-			kingsideClear && !b.anyUnderDirectAttack(true, 5, 6)
+		rightsShift, backRank = 0, 0
 	} else {
-		ourKingLocation = uint8(bits.TrailingZeros64(b.Black.Kings))
 		ptrToOurBitboards = &(b.Black)
-		kingsideClear := allPieces&((1<<61)|(1<<62)) == 0
-		queensideClear := allPieces&((1<<57)|(1<<58)|(1<<59)) == 0
-		// skip the king square, since this won't be called while in check
-		canCastleQueenside = b.blackCanCastleQueenside() &&
-			queensideClear && !b.anyUnderDirectAttack(false, 58, 59)
-		canCastleKingside = b.blackCanCastleKingside() &&
-			kingsideClear && !b.anyUnderDirectAttack(false, 61, 62)
-	}
-	if canCastleKingside {
-		var move Move
-		move.Setfrom(Square(ourKingLocation)).Setto(Square(ourKingLocation + 2))
-		*moveList = append(*moveList, move)
+		rightsShift, backRank = 2, 56
 	}
-	if canCastleQueenside {
+	ourKingLocation := uint8(bits.TrailingZeros64(ptrToOurBitboards.Kings))
+	allPieces := b.White.All | b.Black.All
+	// castling: kingside first, then queenside
+	for side := 1; side >= 0; side-- {
+		rightIdx := rightsShift + uint8(side)
+		if b.castlerights&(1<<rightIdx) == 0 {
+			continue
+		}
+		rookLocation := b.castlerooks[rightIdx]
+		kingDest, rookDest := backRank+2, backRank+3
+		if side == 1 {
+			kingDest, rookDest = backRank+6, backRank+5
+		}
+		if allowDest&(uint64(1)<<kingDest) == 0 {
+			continue
+		}
+		// To castle, every square that the king or rook crosses or lands on must be
+		// empty (apart from the king and rook themselves)...
+		occupancy := allPieces & ^((uint64(1) << ourKingLocation) | (uint64(1) << rookLocation))
+		kingPath := squaresBetweenOnRank(ourKingLocation, kingDest) | (uint64(1) << kingDest)
+		rookPath := squaresBetweenOnRank(rookLocation, rookDest) | (uint64(1) << rookDest)
+		if (kingPath|rookPath)&occupancy != 0 {
+			continue
+		}
+		// ...and the king can't cross or land on an attacked square. The castling
+		// rook is removed, in case it shields the king's destination from a slider.
+		// Skip the king square, since this won't be called while in check.
+		if b.attackedSquares(b.Wtomove, occupancy)&kingPath != 0 {
+			continue
+		}
 		var move Move
-		move.Setfrom(Square(ourKingLocation)).Setto(Square(ourKingLocation - 2))
-		*moveList = append(*moveList, move)
+		if b.Chess960 { // king captures own rook
+			move.Setfrom(Square(ourKingLocation)).Setto(Square(rookLocation))
+		} else {
+			move.Setfrom(Square(ourKingLocation)).Setto(Square(kingDest))
+		}
+		moveList = append(moveList, move)
 	}
+	return moveList
+}
 
-	// non-castling
-	b.kingPushes(moveList, ptrToOurBitboards)
+// Returns the squares strictly between two squares on the same rank.
+func squaresBetweenOnRank(a uint8, b uint8) uint64 {
+	if a > b {
+		a, b = b, a
+	}
+	if b-a < 2 {
+		return 0
+	}
+	return ((uint64(1) << b) - 1) & ^((uint64(1) << (a + 1)) - 1)
 }
 
 // Generate all rook moves using magic bitboards.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) rookMoves(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) rookMoves(moveList []Move, nonpinned uint64, allowDest uint64) []Move {
 	var ourRooks, friendlyPieces uint64
 	if b.Wtomove {
 		ourRooks = b.White.Rooks & nonpinned
@@ -447,13 +552,14 @@ func (b *Board) rookMoves(moveList *[]Move, nonpinned uint64, allowDest uint64)
 		currRook := uint8(bits.TrailingZeros64(ourRooks))
 		ourRooks &= ourRooks - 1
 		targets := CalculateRookMoveBitboard(currRook, allPieces) & (^friendlyPieces) & allowDest
-		genMovesFromTargets(moveList, Square(currRook), targets)
+		moveList = genMovesFromTargets(moveList, Square(currRook), targets)
 	}
+	return moveList
 }
 
 // Generate all bishop moves using magic bitboards.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) bishopMoves(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) bishopMoves(moveList []Move, nonpinned uint64, allowDest uint64) []Move {
 	var ourBishops, friendlyPieces uint64
 	if b.Wtomove {
 		ourBishops = b.White.Bishops & nonpinned
@@ -467,13 +573,14 @@ func (b *Board) bishopMoves(moveList *[]Move, nonpinned uint64, allowDest uint64
 		currBishop := uint8(bits.TrailingZeros64(ourBishops))
 		ourBishops &= ourBishops - 1
 		targets := CalculateBishopMoveBitboard(currBishop, allPieces) & (^friendlyPieces) & allowDest
-		genMovesFromTargets(moveList, Square(currBishop), targets)
+		moveList = genMovesFromTargets(moveList, Square(currBishop), targets)
 	}
+	return moveList
 }
 
 // Generate all queen moves using magic bitboards.
 // Only pieces marked nonpinned can be moved. Only squares in allowDest can be moved to.
-func (b *Board) queenMoves(moveList *[]Move, nonpinned uint64, allowDest uint64) {
+func (b *Board) queenMoves(moveList []Move, nonpinned uint64, allowDest uint64) []Move {
 	var ourQueens, friendlyPieces uint64
 	if b.Wtomove {
 		ourQueens = b.White.Queens & nonpinned
@@ -488,22 +595,24 @@ func (b *Board) queenMoves(moveList *[]Move, nonpinned uint64, allowDest uint64)
 		ourQueens &= ourQueens - 1
 		// bishop motion
 		diag_targets := CalculateBishopMoveBitboard(currQueen, allPieces) & (^friendlyPieces) & allowDest
-		genMovesFromTargets(moveList, Square(currQueen), diag_targets)
+		moveList = genMovesFromTargets(moveList, Square(currQueen), diag_targets)
 		// rook motion
 		ortho_targets := CalculateRookMoveBitboard(currQueen, allPieces) & (^friendlyPieces) & allowDest
-		genMovesFromTargets(moveList, Square(currQueen), ortho_targets)
+		moveList = genMovesFromTargets(moveList, Square(currQueen), ortho_targets)
 	}
+	return moveList
 }
 
 // Helper: converts a targets bitboard into moves, and adds them to the moves list.
-func genMovesFromTargets(moveList *[]Move, origin Square, targets uint64) {
+func genMovesFromTargets(moveList []Move, origin Square, targets uint64) []Move {
 	for targets != 0 {
 		target := bits.TrailingZeros64(targets)
 		targets &= targets - 1
 		var move Move
 		move.Setfrom(origin).Setto(Square(target))
-		*moveList = append(*moveList, move)
+		moveList = append(moveList, move)
 	}
+	return moveList
 }
 
 // Variadic function that returns whether any of the specified squares is being attacked
@@ -623,6 +732,41 @@ func (b *Board) countAttacks(byBlack bool, origin uint8, abortEarly int) (int, u
 	return numAttacks, blockerDestinations
 }
 
+// Computes the set of all squares attacked by one side, with sliders blocked
+// by the given occupancy. Does not modify the board.
+func (b *Board) attackedSquares(byBlack bool, occupancy uint64) uint64 {
+	var attackers *Bitboards
+	var attacked uint64
+	if byBlack {
+		attackers = &(b.Black)
+		attacked = (attackers.Pawns >> 7 & ^(onlyFile[0])) | (attackers.Pawns >> 9 & ^(onlyFile[7]))
+	} else {
+		attackers = &(b.White)
+		attacked = (attackers.Pawns << 9 & ^(onlyFile[0])) | (attackers.Pawns << 7 & ^(onlyFile[7]))
+	}
+	knights := attackers.Knights
+	for knights != 0 {
+		attacked |= knightMasks[bits.TrailingZeros64(knights)]
+		knights &= knights - 1
+	}
+	diagSliders := attackers.Bishops | attackers.Queens
+	for diagSliders != 0 {
+		attacked |= CalculateBishopMoveBitboard(uint8(bits.TrailingZeros64(diagSliders)), occupancy)
+		diagSliders &= diagSliders - 1
+	}
+	orthoSliders := attackers.Rooks | attackers.Queens
+	for orthoSliders != 0 {
+		attacked |= CalculateRookMoveBitboard(uint8(bits.TrailingZeros64(orthoSliders)), occupancy)
+		orthoSliders &= orthoSliders - 1
+	}
+	kings := attackers.Kings
+	for kings != 0 {
+		attacked |= kingMasks[bits.TrailingZeros64(kings)]
+		kings &= kings - 1
+	}
+	return attacked
+}
+
 // Calculates the attack bitboard for a rook. This might include targeted squares
 // that are actually friendly pieces, so the proper usage is:
 // rookTargets := CalculateRookMoveBitboard(myRookLoc, allPieces) & (^myPieces)
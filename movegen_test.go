@@ -14,7 +14,7 @@ func TestPawnPushes(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.pawnPushes(&moves, everything, everything)
+		moves = b.pawnPushes(moves, everything, everything)
 		if len(moves) != v {
 			t.Error("Pawn pushes: wrong length. Expected", v, "but got",
 				len(moves), "for FEN", b.ToFen())
@@ -31,7 +31,7 @@ func TestPawnCaptures(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.pawnCaptures(&moves, everything, everything)
+		moves = b.pawnCaptures(moves, everything, everything)
 		if len(moves) != v {
 			t.Error("Pawn captures: wrong length. Expected", v, "but got",
 				len(moves), "for FEN", b.ToFen())
@@ -64,14 +64,14 @@ func TestKnightPosition0(t *testing.T) {
 	testboard := Board{White: whitepieces, Black: blackpieces, Wtomove: true}
 
 	moves := make([]Move, 0, 45)
-	testboard.knightMoves(&moves, everything, everything)
+	moves = testboard.knightMoves(moves, everything, everything)
 	if len(moves) != 20 {
 		t.Error("Knight moves: wrong length. Expected 20, got", len(moves))
 	}
 
 	testboard.Wtomove = false
 	moves2 := make([]Move, 0, 45)
-	testboard.knightMoves(&moves2, everything, everything)
+	moves2 = testboard.knightMoves(moves2, everything, everything)
 	if len(moves2) != 27 {
 		t.Error("Knight moves: wrong length. Expected 27, got", len(moves2))
 	}
@@ -91,7 +91,7 @@ func TestKingPositions(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.kingMoves(&moves)
+		moves = b.kingMoves(moves, everything)
 		if len(moves) != v {
 			t.Error("King moves: wrong length. Expected", v, "but got",
 				len(moves), "\nFor position:", k)
@@ -113,7 +113,7 @@ func TestRookPositions(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.rookMoves(&moves, everything, everything)
+		moves = b.rookMoves(moves, everything, everything)
 		if len(moves) != v {
 			t.Error("Rook moves: wrong length. Expected", v, "but got", len(moves))
 		}
@@ -130,7 +130,7 @@ func TestBishopPositions(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.bishopMoves(&moves, everything, everything)
+		moves = b.bishopMoves(moves, everything, everything)
 		if len(moves) != v {
 			t.Error("Bishop moves: wrong length. Expected", v, "but got", len(moves))
 		}
@@ -147,7 +147,7 @@ func TestQueenPositions(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.queenMoves(&moves, everything, everything)
+		moves = b.queenMoves(moves, everything, everything)
 		if len(moves) != v {
 			t.Error("Queen moves: wrong length. Expected", v, "but got", len(moves))
 		}
@@ -231,7 +231,7 @@ func TestPinnedBishop(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.generatePinnedMoves(&moves, everything)
+		moves, _ = b.generatePinnedMoves(moves, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for pinned bishops: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 		}
@@ -246,7 +246,7 @@ func TestPinnedKnight(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.generatePinnedMoves(&moves, everything)
+		moves, _ = b.generatePinnedMoves(moves, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for pinned bishops: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 		}
@@ -261,7 +261,7 @@ func TestPinnedQueen(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		b.generatePinnedMoves(&moves, everything)
+		moves, _ = b.generatePinnedMoves(moves, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for pinned bishops: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 		}
@@ -294,7 +294,8 @@ func TestDiagPins(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		result := b.generatePinnedMoves(&moves, everything)
+		var result uint64
+		moves, result = b.generatePinnedMoves(moves, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for diagonal pins: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 		}
@@ -314,6 +315,7 @@ func TestTrickyCornerCases(t *testing.T) {
 	positions := map[string]int{
 		"8/8/8/8/k1Pp3Q/8/8/2K5 b - c3 0 0":  5, // e.p. capture into check
 		"8/8/8/8/1kPp4/8/8/2K1B3 b - c3 0 0": 6, // e.p. breaks check
+		"2b4k/8/8/4pP2/8/7K/8/8 w - e6 0 1":  6, // e.p. along a diagonal pin
 	}
 	for k, v := range positions {
 		b := ParseFen(k)
@@ -357,7 +359,8 @@ func TestOrthoPins(t *testing.T) {
 	for k, v := range positions {
 		moves := make([]Move, 0, 45)
 		b := ParseFen(k)
-		result := b.generatePinnedMoves(&moves, everything)
+		var result uint64
+		moves, result = b.generatePinnedMoves(moves, everything)
 		if len(moves) != v {
 			t.Error("Legal moves for orthogonal pins: wrong length. Expected", v, "but got", len(moves), "for position", b.ToFen())
 			printMoves(moves)
@@ -542,3 +545,98 @@ func TestLegalMoves(t *testing.T) {
 		}
 	}
 }
+
+// Move generation must not modify the board, so that several goroutines can share one.
+func TestConcurrentMoveGeneration(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"8/8/8/K2Pp2q/8/8/8/7k w - e6 0 1",  // e.p. capture exposes the king
+		"4k3/8/8/8/8/8/4r3/4K3 w - - 0 1",   // king can't retreat along the checking ray
+		"8/8/8/8/k2Pp2Q/8/8/3K4 b - d3 0 1", // e.p. capture exposes the king
+	}
+	for _, fen := range fens {
+		b := ParseFen(fen)
+		expected := b.GenerateLegalMoves()
+		done := make(chan []Move)
+		for i := 0; i < 8; i++ {
+			go func() {
+				var moves []Move
+				for j := 0; j < 200; j++ {
+					moves = b.GenerateLegalMoves()
+				}
+				done <- moves
+			}()
+		}
+		for i := 0; i < 8; i++ {
+			moves := <-done
+			if len(moves) != len(expected) {
+				t.Error("Concurrent move generation for", fen, "produced", len(moves),
+					"moves; expected", len(expected))
+			}
+		}
+		if b.ToFen() != fen {
+			t.Error("Move generation modified the board", fen, "into", b.ToFen())
+		}
+	}
+}
+
+func TestCapturesAndQuiets(t *testing.T) {
+	fens := []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"nqn5/P1Pk4/8/8/8/6K1/7p/5N2 w - - 0 1",
+		"r3k3/1ppp1ppr/8/8/2Pp4/8/1P2PPPP/R3K2R b - c3 0 1",
+	}
+	for _, fen := range fens {
+		root := ParseFen(fen)
+		for _, rootMove := range root.GenerateLegalMoves() {
+			b := ParseFen(fen)
+			b.Apply(rootMove)
+			checkCapturesAndQuiets(&b, t)
+		}
+	}
+}
+
+func checkCapturesAndQuiets(b *Board, t *testing.T) {
+	all := b.GenerateLegalMoves()
+	captures := b.GenerateLegalCaptures()
+	quiets := b.GenerateLegalQuiets()
+	seen := make(map[Move]bool)
+	for _, m := range captures {
+		if !IsCapture(m, b) && m.Promote() == Nothing {
+			t.Error("Quiet move", &m, "generated as a capture in", b.ToFen())
+		}
+		seen[m] = true
+	}
+	for _, m := range quiets {
+		if IsCapture(m, b) || m.Promote() != Nothing {
+			t.Error("Capture", &m, "generated as a quiet move in", b.ToFen())
+		}
+		seen[m] = true
+	}
+	if len(captures)+len(quiets) != len(all) || len(seen) != len(all) {
+		t.Error("Captures and quiets don't partition the legal moves in", b.ToFen())
+	}
+	for _, m := range all {
+		if !seen[m] {
+			t.Error("Legal move", &m, "is neither a capture nor a quiet move in", b.ToFen())
+		}
+	}
+	it := b.NewMoveIterator()
+	var staged []Move
+	for m, ok := it.Next(); ok; m, ok = it.Next() {
+		staged = append(staged, m)
+	}
+	if len(staged) != len(all) {
+		t.Error("Move iterator yielded", len(staged), "moves; expected", len(all), "in", b.ToFen())
+		return
+	}
+	for i, m := range staged {
+		if (i < len(captures)) != (IsCapture(m, b) || m.Promote() != Nothing) {
+			t.Error("Move iterator yielded", &m, "out of order in", b.ToFen())
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package dragontoothmg
 
-import "fmt"
+import (
+	"fmt"
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
 
 // Run perft to count the number of moves.
 // Useful for testing and benchmarking.
@@ -8,26 +14,210 @@ func Perft(b *Board, n int) int64 {
 	if n <= 0 {
 		return 1
 	}
-	moves := b.GenerateLegalMoves()
+	var buf [kMaxMoveListLength]Move
+	moves := b.GenerateLegalMovesInto(buf[:0])
 	if n == 1 {
 		return int64(len(moves))
 	}
 	var count int64 = 0
 	for _, move := range moves {
-		unapply := b.Apply(move)
+		undo := b.MakeMove(move)
 		count += Perft(b, n-1)
-		unapply()
+		b.UnmakeMove(move, undo)
 	}
 	return int64(count)
 }
 
+// The breakdown of the moves found at one depth of a perft run, in the categories
+// published on the Chess Programming Wiki.
+type PerftCounts struct {
+	Nodes            int64
+	Captures         int64 // including en passant captures
+	EnPassant        int64
+	Castles          int64
+	Promotions       int64
+	Checks           int64
+	DiscoveredChecks int64 // checks in which the moved piece doesn't give check
+	DoubleChecks     int64
+	Checkmates       int64
+}
+
+// Runs perft, and returns the breakdown of the moves at each depth, with the
+// counts for depth d at index d-1.
+func PerftStats(b *Board, depth int) []PerftCounts {
+	stats := make([]PerftCounts, depth)
+	perftStats(b, stats)
+	return stats
+}
+
+// Adds the moves of the board to stats[0], and those of deeper plies to the rest.
+func perftStats(b *Board, stats []PerftCounts) {
+	if len(stats) == 0 {
+		return
+	}
+	counts := &stats[0]
+	var buf [kMaxMoveListLength]Move
+	for _, move := range b.GenerateLegalMovesInto(buf[:0]) {
+		counts.Nodes++
+		oppPieces := &(b.Black)
+		if !b.Wtomove {
+			oppPieces = &(b.White)
+		}
+		landing := uint64(1) << move.To() // where the moved piece (or rook) lands
+		if b.isCastling(move) {
+			counts.Castles++
+			kingTo, _, rookTo := b.castlingSquares(move, b.Wtomove)
+			landing = (uint64(1) << kingTo) | (uint64(1) << rookTo)
+		} else if oppPieces.All&landing != 0 {
+			counts.Captures++
+		} else if b.enpassant != 0 && move.To() == b.enpassant &&
+			(b.White.Pawns|b.Black.Pawns)&(uint64(1)<<move.From()) != 0 {
+			counts.Captures++
+			counts.EnPassant++
+		}
+		if move.Promote() != Nothing {
+			counts.Promotions++
+		}
+		undo := b.MakeMove(move)
+		if checkers := b.Checkers(); checkers != 0 {
+			counts.Checks++
+			if checkers&landing == 0 {
+				counts.DiscoveredChecks++
+			}
+			if bits.OnesCount64(checkers) > 1 {
+				counts.DoubleChecks++
+			}
+			var replies [kMaxMoveListLength]Move
+			if len(b.GenerateLegalMovesInto(replies[:0])) == 0 {
+				counts.Checkmates++
+			}
+		}
+		perftStats(b, stats[1:])
+		b.UnmakeMove(move, undo)
+	}
+}
+
+// Performs the Perft move count division operation, returning the number of
+// leaf nodes after each legal move instead of printing them.
+func DivideMap(b *Board, n int) map[Move]int64 {
+	var buf [kMaxMoveListLength]Move
+	moves := b.GenerateLegalMovesInto(buf[:0])
+	result := make(map[Move]int64, len(moves))
+	for _, move := range moves {
+		undo := b.MakeMove(move)
+		result[move] = Perft(b, n-1)
+		b.UnmakeMove(move, undo)
+	}
+	return result
+}
+
 // Performs the Perft move count division operation. Useful for debugging.
 func Divide(b *Board, n int) {
-	moves := b.GenerateLegalMoves()
+	var buf [kMaxMoveListLength]Move
+	moves := b.GenerateLegalMovesInto(buf[:0])
 	for _, move := range moves {
-		unapply := b.Apply(move)
+		undo := b.MakeMove(move)
 		result := Perft(b, n-1)
-		unapply()
+		b.UnmakeMove(move, undo)
 		fmt.Printf( /*"Move   #%3d:   "*/ "%-6s =%9d\n" /*i+1, */, &move, result)
 	}
 }
+
+// Runs perft with the root moves split across a number of goroutines, each on its
+// own copy of the board. If workers is not positive, one worker per CPU is used.
+func PerftParallel(b *Board, depth int, workers int) int64 {
+	return PerftParallelWithTable(b, depth, workers, nil)
+}
+
+// Runs perft like PerftParallel, caching subtree counts in a table shared by the
+// workers. The table may be nil, and may be reused across runs.
+func PerftParallelWithTable(b *Board, depth int, workers int, table *PerftTable) int64 {
+	if depth <= 1 {
+		return Perft(b, depth)
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	moves := b.GenerateLegalMoves()
+	var next int64 = -1 // the index of the last root move taken by a worker
+	var count int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			board := *b
+			for {
+				i := atomic.AddInt64(&next, 1)
+				if i >= int64(len(moves)) {
+					return
+				}
+				undo := board.MakeMove(moves[i])
+				atomic.AddInt64(&count, perftWithTable(&board, depth-1, table))
+				board.UnmakeMove(moves[i], undo)
+			}
+		}()
+	}
+	wg.Wait()
+	return count
+}
+
+// Counts moves like Perft, looking up and storing subtree counts in the table.
+func perftWithTable(b *Board, n int, table *PerftTable) int64 {
+	if n <= 1 || table == nil {
+		return Perft(b, n)
+	}
+	if count, ok := table.probe(b.Hash(), n); ok {
+		return count
+	}
+	var buf [kMaxMoveListLength]Move
+	var count int64
+	for _, move := range b.GenerateLegalMovesInto(buf[:0]) {
+		undo := b.MakeMove(move)
+		count += perftWithTable(b, n-1, table)
+		b.UnmakeMove(move, undo)
+	}
+	table.store(b.Hash(), n, count)
+	return count
+}
+
+// A lock-free hash table of perft subtree counts, keyed by board hash and depth,
+// which can be shared by concurrent perft runs.
+type PerftTable struct {
+	entries []perftEntry
+}
+
+// Each entry stores the key XORed with the data, so that a torn write by two
+// goroutines is detected as a mismatched key, instead of returning a wrong count.
+type perftEntry struct {
+	check uint64 // the board hash, XORed with data
+	data  uint64 // the count in the high 56 bits, and the depth in the low 8 bits
+}
+
+// Creates a perft table, using about the given number of megabytes of memory.
+// The number of entries is rounded down to a power of two.
+func NewPerftTable(megabytes int) *PerftTable {
+	size := 1
+	for size*2*16 <= megabytes<<20 {
+		size *= 2
+	}
+	return &PerftTable{entries: make([]perftEntry, size)}
+}
+
+// Returns the stored count for a position and depth, if there is one.
+func (t *PerftTable) probe(hash uint64, depth int) (int64, bool) {
+	entry := &t.entries[hash&uint64(len(t.entries)-1)]
+	data := atomic.LoadUint64(&entry.data)
+	if atomic.LoadUint64(&entry.check)^data != hash || data&0xFF != uint64(depth) {
+		return 0, false
+	}
+	return int64(data >> 8), true
+}
+
+// Stores the count for a position and depth, replacing any older entry.
+func (t *PerftTable) store(hash uint64, depth int, count int64) {
+	entry := &t.entries[hash&uint64(len(t.entries)-1)]
+	data := uint64(count)<<8 | uint64(depth)
+	atomic.StoreUint64(&entry.check, hash^data)
+	atomic.StoreUint64(&entry.data, data)
+}
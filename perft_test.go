@@ -13,123 +13,121 @@ func testDivide(t *testing.T) {
 	Divide(&b, 1)
 }
 
-// Uncomment lines in the solution maps for more thorough testing, although this takes longer
-func TestMate(t *testing.T) {
-	perftSolutions := map[int]int64{
-		1: 0,
-		2: 0,
-		3: 0,
-		4: 0,
+// Perft and buffer-reusing move generation should run without heap allocation.
+func TestPerftAllocations(t *testing.T) {
+	b := ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if allocs := testing.AllocsPerRun(5, func() { Perft(&b, 3) }); allocs != 0 {
+		t.Error("Perft allocated", allocs, "times per run")
 	}
-	pos := "5k1R/5p2/5P2/8/8/2r5/2rR2K1/4B3 b - - 0 1"
-	checkPerftResults(pos, perftSolutions, t)
-}
-
-// Uncomment lines in the solution maps for more thorough testing, although this takes longer
-func TestStartingPosition(t *testing.T) {
-	perftSolutions := map[int]int64{
-		1: 20,
-		2: 400,
-		3: 8902,
-		4: 197281,
-		5: 4865609,
-		6: 119060324,
+	var buf [kMaxMoveListLength]Move
+	if allocs := testing.AllocsPerRun(100, func() { b.GenerateLegalMovesInto(buf[:0]) }); allocs != 0 {
+		t.Error("GenerateLegalMovesInto allocated", allocs, "times per run")
 	}
-	checkPerftResults(Startpos, perftSolutions, t)
-}
-
-func TestKiwipetePosition(t *testing.T) {
-	perftSolutions := map[int]int64{
-		1: 48,
-		2: 2039,
-		3: 97862,
-		4: 4085603,
-		5: 193690690,
+	// A too-small buffer is grown as needed.
+	if moves := b.GenerateLegalMovesInto(buf[:0:4]); len(moves) != 48 {
+		t.Error("GenerateLegalMovesInto with a small buffer produced", len(moves), "moves; expected 48")
 	}
-	pos := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0"
-	checkPerftResults(pos, perftSolutions, t)
 }
 
-func TestEndgameRP(t *testing.T) {
-	perftSolutions := map[int]int64{
-		1: 14,
-		2: 191,
-		3: 2812,
-		4: 43238,
-		5: 674624,
-		6: 11030083,
-		// 7: 178633661,
+// Standard positions have the same perft results with Chess960 castling
+func TestChess960Standard(t *testing.T) {
+	for _, pos := range []string{Startpos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0"} {
+		b := ParseFen(pos)
+		expected := Perft(&b, 3)
+		b.Chess960 = true
+		if result := Perft(&b, 3); result != expected {
+			t.Error("Chess960 perft of", pos, "was", result, "; expected", expected)
+		}
 	}
-	pos := "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 0"
-	checkPerftResults(pos, perftSolutions, t)
 }
 
-func TestMidgameDense(t *testing.T) {
-	perftSolutions := map[int]int64{
-		1: 6,
-		2: 264,
-		3: 9467,
-		4: 422333,
-		5: 15833292,
-		// 6: 706045033,
+func TestPerftParallel(t *testing.T) {
+	positions := map[string]int64{
+		Startpos: 197281,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1": 4085603,
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1":                            43238,
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9":    326672,
 	}
-	pos := "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1"
-	checkPerftResults(pos, perftSolutions, t)
-}
-
-func TestMidgame2(t *testing.T) {
-	perftSolutions := map[int]int64{
-		1: 44,
-		2: 1486,
-		3: 62379,
-		4: 2103487,
-		5: 89941194,
+	// A tiny table forces frequent replacement of entries
+	tables := []*PerftTable{nil, NewPerftTable(0), NewPerftTable(16)}
+	for fen, expected := range positions {
+		for _, workers := range []int{0, 1, 3} {
+			for _, table := range tables {
+				b := ParseFen(fen)
+				if result := PerftParallelWithTable(&b, 4, workers, table); result != expected {
+					t.Error("Parallel perft of", fen, "with", workers, "workers was", result, "; expected", expected)
+				}
+				if original := ParseFen(fen); b != original {
+					t.Error("Parallel perft corrupted board state.")
+				}
+			}
+		}
+		b := ParseFen(fen)
+		if result := PerftParallel(&b, 1, 2); result != Perft(&b, 1) {
+			t.Error("Parallel perft of", fen, "at depth 1 was", result)
+		}
 	}
-	pos := "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8"
-	checkPerftResults(pos, perftSolutions, t)
 }
 
-func TestMidgame3(t *testing.T) {
-	perftSolutions := map[int]int64{
-		1: 46,
-		2: 2079,
-		3: 89890,
-		4: 3894594,
-		5: 164075551,
-		// 6: 6923051137,
-		// 7: 287188994746,
-		// 8: 11923589843526,
-		// 9: 490154852788714,
+// The expected counts are from the Chess Programming Wiki
+func TestPerftStats(t *testing.T) {
+	positions := map[string][]PerftCounts{
+		Startpos: {
+			{20, 0, 0, 0, 0, 0, 0, 0, 0},
+			{400, 0, 0, 0, 0, 0, 0, 0, 0},
+			{8902, 34, 0, 0, 0, 12, 0, 0, 0},
+			{197281, 1576, 0, 0, 0, 469, 0, 0, 8},
+			{4865609, 82719, 258, 0, 0, 27351, 6, 0, 347},
+		},
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1": {
+			{48, 8, 0, 2, 0, 0, 0, 0, 0},
+			{2039, 351, 1, 91, 0, 3, 0, 0, 0},
+			{97862, 17102, 45, 3162, 0, 993, 0, 0, 1},
+			{4085603, 757163, 1929, 128013, 15172, 25523, 42, 6, 43},
+		},
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1": {
+			{14, 1, 0, 0, 0, 2, 0, 0, 0},
+			{191, 14, 0, 0, 0, 10, 0, 0, 0},
+			{2812, 209, 2, 0, 0, 267, 3, 0, 0},
+			{43238, 3348, 123, 0, 0, 1680, 106, 0, 17},
+			{674624, 52051, 1165, 0, 0, 52950, 1292, 3, 0},
+		},
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1": {
+			{6, 0, 0, 0, 0, 0, 0, 0, 0},
+			{264, 87, 0, 6, 48, 10, 0, 0, 0},
+			{9467, 1021, 4, 0, 120, 38, 2, 0, 22},
+			{422333, 131393, 0, 7795, 60032, 15492, 19, 0, 5},
+		},
 	}
-	pos := "r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10"
-	checkPerftResults(pos, perftSolutions, t)
-}
-
-func TestPromotions(t *testing.T) {
-	perftSolutions := map[int]int64{
-		1: 24,
-		2: 496,
-		3: 9483,
-		4: 182838,
-		5: 3605103,
-		6: 71179139,
+	for fen, expected := range positions {
+		b := ParseFen(fen)
+		stats := PerftStats(&b, len(expected))
+		for i := range expected {
+			if stats[i] != expected[i] {
+				t.Errorf("Perft stats of %v at depth %d were %+v; expected %+v", fen, i+1, stats[i], expected[i])
+			}
+		}
+		if original := ParseFen(fen); b != original {
+			t.Error("Perft stats corrupted board state.")
+		}
 	}
-	pos := "n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1"
-	checkPerftResults(pos, perftSolutions, t)
 }
 
-func checkPerftResults(fen string, perftSolutions map[int]int64, t *testing.T) {
-	b := ParseFen(fen)
-	for i := 1; i <= len(perftSolutions); i++ {
-		beforeFen := b.ToFen()
-		result := Perft(&b, i)
-		afterFen := b.ToFen()
-		if beforeFen != afterFen {
-			t.Error("Perft corrupted board state.")
-		}
-		if result != perftSolutions[i] {
-			t.Error("Perft error in position\n", b.ToFen(), "\nExpected",
-				perftSolutions[i], "but got", result, "for depth", i)
+func TestDivideMap(t *testing.T) {
+	b := ParseFen(Startpos)
+	divide := DivideMap(&b, 3)
+	expected := map[string]int64{"e2e4": 600, "a2a3": 380, "g1f3": 440}
+	for move, count := range expected {
+		if result := divide[parseMove(move)]; result != count {
+			t.Error("Divide of", move, "was", result, "; expected", count)
 		}
 	}
+	var total int64
+	for _, count := range divide {
+		total += count
+	}
+	if len(divide) != 20 || total != 8902 {
+		t.Error("Divide had", len(divide), "moves and", total, "nodes; expected 20 and 8902")
+	}
 }
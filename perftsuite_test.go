@@ -0,0 +1,53 @@
+package dragontoothmg_test
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+	"github.com/dylhunn/dragontoothmg/epd"
+)
+
+// Checks the perft counts of testdata/perftsuite.epd. This is the one list of
+// perft positions; add new ones to the file. Counts of up to 200 million nodes
+// are checked, which takes in the starting position to depth 6 and Kiwipete to
+// depth 5, and only those of up to 100,000 nodes with -short. Run cmd/perftsuite
+// on the file to check all of them.
+func TestPerftSuite(t *testing.T) {
+	maxNodes := int64(200000000)
+	if testing.Short() {
+		maxNodes = 100000
+	}
+	f, err := os.Open("testdata/perftsuite.epd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := epd.NewReader(f)
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts, err := record.PerftCounts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for depth, expected := range counts {
+			if expected > maxNodes {
+				continue
+			}
+			b := record.Board
+			if result := dragontoothmg.Perft(&b, depth); result != expected {
+				t.Error("Perft of", b.ToFen(), "at depth", depth, "was", result, "; expected", expected)
+			}
+			if b != record.Board {
+				t.Error("Perft corrupted board state of", record.Board.ToFen())
+			}
+		}
+	}
+}
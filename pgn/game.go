@@ -0,0 +1,148 @@
+// Package pgn reads and writes chess games in Portable Game Notation (PGN).
+//
+// Games are represented as trees of moves, so that comments, numeric annotation
+// glyphs (NAGs) and recursive variations survive a round trip.
+package pgn
+
+import (
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A Tag is a PGN tag pair, such as [Event "F/S Return Match"].
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// A Game is a PGN game: its tag pairs, its tree of moves, and its result.
+type Game struct {
+	Tags   []Tag  // The tag pairs, in the order they appear
+	Root   *Node  // The starting position. The root node has no move.
+	Result string // One of "1-0", "0-1", "1/2-1/2" or "*"
+}
+
+// A Node is a position in the game tree, reached by playing Move from the parent.
+type Node struct {
+	Move   dragontoothmg.Move
+	Parent *Node
+	// The continuations from this position. The first child is the main line,
+	// and any others are alternative variations.
+	Children []*Node
+	// The comment before this move, if it starts a variation.
+	StartingComment string
+	// The comment after this move (or, for the root, before the first move).
+	Comment string
+	// Numeric annotation glyphs for the move, e.g. 1 for "!" and 4 for "??".
+	NAGs  []int
+	board dragontoothmg.Board
+}
+
+// Creates a game that starts from the given position, with an unknown result.
+// If the position is not the standard starting position, the SetUp and FEN tags
+// are added, and Chess960 games get a Variant tag.
+func NewGame(start dragontoothmg.Board) *Game {
+	g := &Game{Root: &Node{board: start}, Result: "*"}
+	if start.Chess960 {
+		g.SetTag("Variant", "Chess960")
+	}
+	if fen := start.ToFen(); fen != dragontoothmg.Startpos {
+		g.SetTag("SetUp", "1")
+		g.SetTag("FEN", fen)
+	}
+	return g
+}
+
+// Returns the value of the named tag, or the empty string if the tag is not present.
+func (g *Game) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// Sets the value of the named tag, adding the tag if it is not already present.
+func (g *Game) SetTag(name, value string) {
+	for i := range g.Tags {
+		if g.Tags[i].Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, Tag{name, value})
+}
+
+// Returns the moves of the main line, from the starting position.
+func (g *Game) Mainline() []dragontoothmg.Move {
+	var moves []dragontoothmg.Move
+	for n := g.Root.Next(); n != nil; n = n.Next() {
+		moves = append(moves, n.Move)
+	}
+	return moves
+}
+
+// Returns an iterator over the positions of the main line, starting with
+// the starting position.
+func (g *Game) Boards() *BoardIterator {
+	return &BoardIterator{next: g.Root}
+}
+
+// Returns the position after this node's move.
+func (n *Node) Board() dragontoothmg.Board {
+	return n.board
+}
+
+// Returns the main line continuation from this position, or nil if there is none.
+func (n *Node) Next() *Node {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[0]
+}
+
+// Adds a continuation to this position, and returns the new node.
+// The first continuation added is the main line; later ones are variations.
+// The move must be legal in this position.
+func (n *Node) AddChild(m dragontoothmg.Move) *Node {
+	child := &Node{Move: m, Parent: n, board: n.board}
+	child.board.Apply(m)
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// An iterator over the positions of a game's main line.
+//
+//	for it := game.Boards(); it.Next(); {
+//		board := it.Board()
+//		...
+//	}
+type BoardIterator struct {
+	next    *Node
+	current *Node
+}
+
+// Advances to the next position, returning false when the main line is exhausted.
+func (it *BoardIterator) Next() bool {
+	if it.next == nil {
+		return false
+	}
+	it.current = it.next
+	it.next = it.current.Next()
+	return true
+}
+
+// Returns the current position.
+func (it *BoardIterator) Board() dragontoothmg.Board {
+	return it.current.board
+}
+
+// Returns the move that led to the current position, or 0 for the starting position.
+func (it *BoardIterator) Move() dragontoothmg.Move {
+	return it.current.Move
+}
+
+// Returns the game tree node of the current position.
+func (it *BoardIterator) Node() *Node {
+	return it.current
+}
@@ -0,0 +1,439 @@
+package pgn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+type tokenKind int
+
+const (
+	tokEOF          tokenKind = iota
+	tokSymbol                 // move, move number, or result
+	tokString                 // quoted tag value
+	tokComment                // {brace} or ;rest-of-line comment
+	tokNAG                    // $n or a suffix annotation like !?
+	tokPeriod                 // .
+	tokAsterisk               // *
+	tokOpenBracket            // [
+	tokCloseBracket           // ]
+	tokOpenParen              // (
+	tokCloseParen             // )
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+// Traditional suffix annotations, and their equivalent NAGs.
+var suffixAnnotations = map[string]int{
+	"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6,
+}
+
+// A Reader reads a stream of PGN games, such as a multi-game PGN file.
+type Reader struct {
+	r      *bufio.Reader
+	line   int
+	peeked *token
+	// Whether the reader is at the start of a line, where % escapes are recognized.
+	lineStart bool
+	// The value of lineStart before the last byte read, for unreadByte.
+	prevLineStart bool
+}
+
+// Creates a Reader that reads PGN games from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), line: 1, lineStart: true}
+}
+
+// Reads the next game. Returns io.EOF when there are no more games.
+// If a game has an error (such as an illegal move), the rest of that game is
+// skipped, so that Next can be called again to read the following games.
+func (r *Reader) Next() (*Game, error) {
+	tok, err := r.peek()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokEOF {
+		return nil, io.EOF
+	}
+	g := &Game{}
+	if err := r.readTags(g); err != nil {
+		r.skipGame(true)
+		return nil, err
+	}
+	// The FEN of a Chess960 game may use KQkq for rooks on any file
+	fen, parse := g.Tag("FEN"), dragontoothmg.ParseFenStrict
+	if strings.EqualFold(g.Tag("Variant"), "Chess960") {
+		parse = dragontoothmg.ParseChess960Fen
+		if fen == "" {
+			fen = dragontoothmg.Startpos
+		}
+	}
+	start := dragontoothmg.ParseFen(dragontoothmg.Startpos)
+	if fen != "" {
+		start, err = parse(fen)
+		if err != nil {
+			r.skipGame(false)
+			return nil, fmt.Errorf("pgn: line %d: %v", tok.line, err)
+		}
+	}
+	g.Root = &Node{board: start}
+	if err := r.readMovetext(g); err != nil {
+		r.skipGame(false)
+		return nil, err
+	}
+	if g.Result == "" {
+		g.Result = g.Tag("Result")
+	}
+	if g.Result == "" {
+		g.Result = "*"
+	}
+	return g, nil
+}
+
+// Reads the tag pair section of a game.
+func (r *Reader) readTags(g *Game) error {
+	for {
+		tok, err := r.peek()
+		if err != nil {
+			return err
+		}
+		if tok.kind != tokOpenBracket {
+			return nil
+		}
+		r.peeked = nil
+		name, err := r.next()
+		if err != nil {
+			return err
+		}
+		value, err := r.next()
+		if err != nil {
+			return err
+		}
+		closing, err := r.next()
+		if err != nil {
+			return err
+		}
+		if name.kind != tokSymbol || value.kind != tokString || closing.kind != tokCloseBracket {
+			return fmt.Errorf("pgn: line %d: malformed tag pair", tok.line)
+		}
+		g.Tags = append(g.Tags, Tag{name.value, value.value})
+	}
+}
+
+// Reads the movetext section of a game, up to and including the result.
+func (r *Reader) readMovetext(g *Game) error {
+	current := g.Root
+	var variations []*Node // the nodes to return to, at the end of each variation
+	startingVariation := false
+	var startingComment string
+	for {
+		tok, err := r.peek()
+		if err != nil {
+			return err
+		}
+		if tok.kind == tokEOF || tok.kind == tokOpenBracket {
+			// A game without a result token; leave the next game's tags unread.
+			if len(variations) != 0 {
+				return fmt.Errorf("pgn: line %d: unterminated variation", tok.line)
+			}
+			return nil
+		}
+		r.peeked = nil
+		switch tok.kind {
+		case tokAsterisk:
+			g.Result = "*"
+			return nil
+		case tokSymbol:
+			if isResult(tok.value) {
+				g.Result = tok.value
+				return nil
+			}
+			if isMoveNumber(tok.value) {
+				continue
+			}
+			board := current.board
+			mv, err := board.ParseSAN(tok.value)
+			if err != nil {
+				return fmt.Errorf("pgn: line %d: %v", tok.line, err)
+			}
+			current = current.AddChild(mv)
+			if startingVariation {
+				current.StartingComment = startingComment
+				startingVariation, startingComment = false, ""
+			}
+		case tokPeriod:
+		case tokNAG:
+			if n, ok := suffixAnnotations[tok.value]; ok {
+				current.NAGs = append(current.NAGs, n)
+				continue
+			}
+			n, err := strconv.Atoi(tok.value[1:])
+			if err != nil || n < 0 || n > 255 {
+				return fmt.Errorf("pgn: line %d: invalid NAG %q", tok.line, tok.value)
+			}
+			current.NAGs = append(current.NAGs, n)
+		case tokComment:
+			if startingVariation {
+				startingComment = joinComments(startingComment, tok.value)
+			} else {
+				current.Comment = joinComments(current.Comment, tok.value)
+			}
+		case tokOpenParen:
+			if current.Parent == nil {
+				return fmt.Errorf("pgn: line %d: variation before the first move", tok.line)
+			}
+			variations = append(variations, current)
+			current = current.Parent
+			startingVariation = true
+		case tokCloseParen:
+			if len(variations) == 0 || startingVariation {
+				return fmt.Errorf("pgn: line %d: unexpected )", tok.line)
+			}
+			current = variations[len(variations)-1]
+			variations = variations[:len(variations)-1]
+		default:
+			return fmt.Errorf("pgn: line %d: unexpected %q in movetext", tok.line, tok.value)
+		}
+	}
+}
+
+// Skips the remainder of a game after an error. If the error was in the tag
+// pair section, the rest of the tags (up to the movetext) are skipped first,
+// since their brackets do not start the next game.
+func (r *Reader) skipGame(inTags bool) {
+	inTag := inTags // in an unterminated tag pair, the error may be before its ]
+	for {
+		tok, err := r.peek()
+		if err != nil || tok.kind == tokEOF {
+			return
+		}
+		if tok.kind == tokOpenBracket && !inTags {
+			// Tags that follow movetext belong to the next game.
+			return
+		}
+		r.peeked = nil
+		switch {
+		case tok.kind == tokAsterisk || (tok.kind == tokSymbol && isResult(tok.value)):
+			return
+		case tok.kind == tokOpenBracket:
+			inTag = true
+		case tok.kind == tokCloseBracket:
+			inTag = false
+		case !inTag:
+			inTags = false // the first token of the movetext
+		}
+	}
+}
+
+func isResult(s string) bool {
+	return s == "1-0" || s == "0-1" || s == "1/2-1/2"
+}
+
+func isMoveNumber(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func joinComments(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + " " + b
+}
+
+// Returns the next token without consuming it.
+func (r *Reader) peek() (token, error) {
+	if r.peeked == nil {
+		tok, err := r.scan()
+		if err != nil {
+			return tok, err
+		}
+		r.peeked = &tok
+	}
+	return *r.peeked, nil
+}
+
+// Consumes and returns the next token.
+func (r *Reader) next() (token, error) {
+	tok, err := r.peek()
+	r.peeked = nil
+	return tok, err
+}
+
+func (r *Reader) readByte() (byte, error) {
+	c, err := r.r.ReadByte()
+	if err != nil {
+		return c, err
+	}
+	r.prevLineStart = r.lineStart
+	r.lineStart = c == '\n'
+	if c == '\n' {
+		r.line++
+	}
+	return c, nil
+}
+
+func (r *Reader) unreadByte(c byte) {
+	r.r.UnreadByte()
+	r.lineStart = r.prevLineStart
+	if c == '\n' {
+		r.line--
+	}
+}
+
+// Reads until (and consuming) the delimiter, returning the text before it.
+func (r *Reader) readUntil(delim byte) (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := r.readByte()
+		if err != nil {
+			return sb.String(), err
+		}
+		if c == delim {
+			return sb.String(), nil
+		}
+		sb.WriteByte(c)
+	}
+}
+
+// Lexes the next token from the input.
+func (r *Reader) scan() (token, error) {
+	for {
+		atLineStart := r.lineStart
+		c, err := r.readByte()
+		if err == io.EOF {
+			return token{kind: tokEOF, line: r.line}, nil
+		} else if err != nil {
+			return token{}, err
+		}
+		line := r.line
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		case c == '%' && atLineStart: // escape mechanism: ignore the line
+			if _, err := r.readUntil('\n'); err != nil && err != io.EOF {
+				return token{}, err
+			}
+			continue
+		case c == '{':
+			text, err := r.readUntil('}')
+			if err == io.EOF {
+				return token{}, fmt.Errorf("pgn: line %d: unterminated comment", line)
+			} else if err != nil {
+				return token{}, err
+			}
+			return token{kind: tokComment, value: strings.Join(strings.Fields(text), " "), line: line}, nil
+		case c == ';':
+			text, err := r.readUntil('\n')
+			if err != nil && err != io.EOF {
+				return token{}, err
+			}
+			return token{kind: tokComment, value: strings.Join(strings.Fields(text), " "), line: line}, nil
+		case c == '"':
+			return r.scanString(line)
+		case c == '$':
+			digits, err := r.scanWhile(func(c byte) bool { return c >= '0' && c <= '9' })
+			if err != nil {
+				return token{}, err
+			}
+			return token{kind: tokNAG, value: "$" + digits, line: line}, nil
+		case c == '!' || c == '?':
+			rest, err := r.scanWhile(func(c byte) bool { return c == '!' || c == '?' })
+			if err != nil {
+				return token{}, err
+			}
+			return token{kind: tokNAG, value: string(c) + rest, line: line}, nil
+		case c == '.':
+			return token{kind: tokPeriod, value: ".", line: line}, nil
+		case c == '*':
+			return token{kind: tokAsterisk, value: "*", line: line}, nil
+		case c == '[':
+			return token{kind: tokOpenBracket, value: "[", line: line}, nil
+		case c == ']':
+			return token{kind: tokCloseBracket, value: "]", line: line}, nil
+		case c == '(':
+			return token{kind: tokOpenParen, value: "(", line: line}, nil
+		case c == ')':
+			return token{kind: tokCloseParen, value: ")", line: line}, nil
+		case isSymbolStart(c):
+			rest, err := r.scanWhile(isSymbolContinuation)
+			if err != nil {
+				return token{}, err
+			}
+			return token{kind: tokSymbol, value: string(c) + rest, line: line}, nil
+		default:
+			return token{}, fmt.Errorf("pgn: line %d: unexpected character %q", line, c)
+		}
+	}
+}
+
+// Reads a quoted string, after the opening quote, handling backslash escapes.
+func (r *Reader) scanString(line int) (token, error) {
+	var sb strings.Builder
+	for {
+		c, err := r.readByte()
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("pgn: line %d: unterminated string", line)
+			}
+			return token{}, err
+		}
+		switch c {
+		case '"':
+			return token{kind: tokString, value: sb.String(), line: line}, nil
+		case '\\':
+			escaped, err := r.readByte()
+			if err != nil {
+				return token{}, errors.New("pgn: unterminated string")
+			}
+			sb.WriteByte(escaped)
+		case '\n':
+			return token{}, fmt.Errorf("pgn: line %d: unterminated string", line)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// Reads bytes for as long as they satisfy the predicate.
+func (r *Reader) scanWhile(accept func(byte) bool) (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := r.readByte()
+		if err == io.EOF {
+			return sb.String(), nil
+		} else if err != nil {
+			return "", err
+		}
+		if !accept(c) {
+			r.unreadByte(c)
+			return sb.String(), nil
+		}
+		sb.WriteByte(c)
+	}
+}
+
+func isSymbolStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isSymbolContinuation(c byte) bool {
+	return isSymbolStart(c) || strings.IndexByte("_+#=:-/", c) >= 0
+}
@@ -0,0 +1,205 @@
+package pgn
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+const fischerSpassky = `[Event "F/S Return Match"]
+[Site "Belgrade, Serbia JUG"]
+[Date "1992.11.04"]
+[Round "29"]
+[White "Fischer, Robert J."]
+[Black "Spassky, Boris V."]
+[Result "1/2-1/2"]
+
+1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 {This opening is called the Ruy Lopez.}
+4. Ba4 Nf6 5. O-O Be7 6. Re1 b5 7. Bb3 d6 8. c3 O-O 9. h3 Nb8 10. d4 Nbd7
+11. c4 c6 12. cxb5 axb5 13. Nc3 Bb7 14. Bg5 b4 15. Nb1 h6 16. Bh4 c5 17. dxe5
+Nxe4 18. Bxe7 Qxe7 19. exd6 Qf6 20. Nbd2 Nxd6 21. Nc4 Nxc4 22. Bxc4 Nb6
+23. Ne5 Rae8 24. Bxf7+ Rxf7 25. Nxf7 Rxe1+ 26. Qxe1 Kxf7 27. Qe3 Qg5 28. Qxg5
+hxg5 29. b3 Ke6 30. a3 Kd6 31. axb4 cxb4 32. Ra5 Nd5 33. f3 Bc8 34. Kf2 Bf5
+35. Ra7 g6 36. Ra6+ Kc5 37. Ke1 Nf4 38. g3 Nxh3 39. Kd2 Kb5 40. Rd6 Kc5 41. Ra6
+Nf2 42. g4 Bd3 43. Re6 1/2-1/2
+`
+
+const annotatedGames = `% A file-level escape line, which is ignored
+[Event "Annotated"]
+[White "A \"quoted\" name"]
+[Result "1-0"]
+
+{A game comment.} 1. e4! e5 2. Nf3 $1 (2. f4 {King's Gambit} exf4 (2... d5 3. exd5)
+3. Nf3) (2. Bc4) 2... Nc6 ; a rest-of-line comment
+3. Bb5 a6?! 4. Ba4 (4. Bxc6 dxc6 5. O-O) 4... Nf6 1-0
+
+[Event "From a position"]
+[SetUp "1"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 b - - 0 40"]
+
+40... Kd7 41. e4 Ke6 *
+
+[Event "Illegal"]
+
+1. e4 e5 2. Ke3 Nc6 0-1
+
+[Event "No result"]
+
+1. d4 d5 2. c4`
+
+func TestReadFischerSpassky(t *testing.T) {
+	r := NewReader(strings.NewReader(fischerSpassky))
+	g, err := r.Next()
+	if err != nil {
+		t.Fatal("Failed to read game:", err)
+	}
+	if g.Tag("White") != "Fischer, Robert J." || g.Tag("Round") != "29" || g.Tag("Missing") != "" {
+		t.Error("Tags read incorrectly:", g.Tags)
+	}
+	if g.Result != "1/2-1/2" {
+		t.Error("Wrong result:", g.Result)
+	}
+	moves := g.Mainline()
+	if len(moves) != 85 {
+		t.Error("Expected 85 plies but got", len(moves))
+	}
+	if moves[0].String() != "e2e4" || moves[8].String() != "e1g1" || moves[84].String() != "a6e6" {
+		t.Error("Moves read incorrectly:", moves[0].String(), moves[8].String(), moves[84].String())
+	}
+	if comment := g.Root.Children[0].Children[0].Children[0].Children[0].Children[0].Children[0].Comment; comment != "This opening is called the Ruy Lopez." {
+		t.Error("Wrong comment:", comment)
+	}
+	positions := 0
+	var last dragontoothmg.Board
+	for it := g.Boards(); it.Next(); {
+		last = it.Board()
+		if positions == 0 && (it.Move() != 0 || last.ToFen() != dragontoothmg.Startpos) {
+			t.Error("The iterator should start with the starting position.")
+		}
+		positions++
+	}
+	if positions != 86 {
+		t.Error("Expected 86 positions but got", positions)
+	}
+	if fen := last.ToFen(); fen != "8/8/4R1p1/2k3p1/1p4P1/1P1b1P2/3K1n2/8 b - - 2 43" {
+		t.Error("Wrong final position:", fen)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Error("Expected EOF, but got", err)
+	}
+}
+
+func TestReadAnnotatedGames(t *testing.T) {
+	r := NewReader(strings.NewReader(annotatedGames))
+	g, err := r.Next()
+	if err != nil {
+		t.Fatal("Failed to read game:", err)
+	}
+	if g.Tag("White") != `A "quoted" name` {
+		t.Error("Escaped tag read incorrectly:", g.Tag("White"))
+	}
+	if g.Result != "1-0" || g.Root.Comment != "A game comment." {
+		t.Error("Game result or comment read incorrectly:", g.Result, g.Root.Comment)
+	}
+	e4 := g.Root.Next()
+	if len(e4.NAGs) != 1 || e4.NAGs[0] != 1 {
+		t.Error("Suffix annotation read incorrectly:", e4.NAGs)
+	}
+	afterE5 := e4.Next()
+	if len(afterE5.Children) != 3 {
+		t.Fatal("Expected 3 continuations after 1... e5, but got", len(afterE5.Children))
+	}
+	nf3, f4, bc4 := afterE5.Children[0], afterE5.Children[1], afterE5.Children[2]
+	if nf3.Move.String() != "g1f3" || f4.Move.String() != "f2f4" || bc4.Move.String() != "f1c4" {
+		t.Error("Variations read incorrectly")
+	}
+	if len(nf3.NAGs) != 1 || nf3.NAGs[0] != 1 || f4.Comment != "King's Gambit" {
+		t.Error("Variation annotations read incorrectly")
+	}
+	if len(f4.Children) != 2 || f4.Children[1].Move.String() != "d7d5" || len(f4.Children[1].Children) != 1 {
+		t.Error("Nested variation read incorrectly")
+	}
+	if nf3.Next().Comment != "a rest-of-line comment" {
+		t.Error("Rest-of-line comment read incorrectly:", nf3.Next().Comment)
+	}
+	if len(g.Mainline()) != 8 {
+		t.Error("Expected 8 plies in the main line but got", len(g.Mainline()))
+	}
+
+	g, err = r.Next()
+	if err != nil {
+		t.Fatal("Failed to read game with a FEN tag:", err)
+	}
+	moves := g.Mainline()
+	if len(moves) != 3 || moves[0].String() != "e8d7" || g.Result != "*" {
+		t.Error("Game with a FEN tag read incorrectly")
+	}
+
+	if _, err = r.Next(); err == nil || !strings.Contains(err.Error(), "line") {
+		t.Error("Expected an error with a line number for an illegal move, but got", err)
+	}
+
+	g, err = r.Next()
+	if err != nil {
+		t.Fatal("Failed to read the game after an illegal game:", err)
+	}
+	if g.Tag("Event") != "No result" || len(g.Mainline()) != 3 || g.Result != "*" {
+		t.Error("Game without a result read incorrectly")
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Error("Expected EOF, but got", err)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	malformed := []string{
+		`[Event "Unterminated`,
+		`[Event Missing quotes]`,
+		`1. e4 (e5`,
+		`1. e4 e5) 2. Nf3`,
+		`(1. e4) 1. d4`,
+		`1. e4 {unterminated`,
+		`1. e4 $x`,
+		`[FEN "not a fen"]` + "\n\n1. e4",
+		`1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf8#`,
+	}
+	for _, text := range malformed {
+		r := NewReader(strings.NewReader(text))
+		if _, err := r.Next(); err == nil || err == io.EOF {
+			t.Error("Expected an error for malformed PGN:", text, err)
+		}
+	}
+}
+
+func TestSkipMalformedTags(t *testing.T) {
+	games := []string{
+		"[Event \"Bad\" junk]\n[Site \"Skipped\"]\n\n1. e4 *\n\n[Event \"Next\"]\n\n1. d4 *",
+		"[Event]\n[Site \"Skipped\"]\n\n1. e4 e5 1-0\n[Event \"Next\"]\n1. d4 *",
+		"[Event \"Unterminated\"\n[Site \"Skipped\"]\n1. e4 [Event \"Next\"]\n1. d4 *",
+	}
+	for _, text := range games {
+		r := NewReader(strings.NewReader(text))
+		if _, err := r.Next(); err == nil || err == io.EOF {
+			t.Error("Expected an error for malformed tags:", text, err)
+		}
+		g, err := r.Next()
+		if err != nil || g.Tag("Event") != "Next" || len(g.Mainline()) != 1 {
+			t.Error("Did not skip to the next game after malformed tags:", text, g, err)
+		}
+	}
+}
+
+func TestUnreadByteLineStart(t *testing.T) {
+	r := NewReader(strings.NewReader("\n%"))
+	r.readByte()
+	c, _ := r.readByte()
+	r.unreadByte(c)
+	if !r.lineStart {
+		t.Error("Unreading a byte did not restore the start of line")
+	}
+	if tok, err := r.scan(); err != nil || tok.kind != tokEOF {
+		t.Error("Escape line after an unread byte was not ignored:", tok, err)
+	}
+}
@@ -0,0 +1,125 @@
+package pgn
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The maximum length of a movetext line, per the PGN export format.
+const maxLineLength = 80
+
+// A Writer writes games in PGN export format.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// Creates a Writer that writes PGN games to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bufio.NewWriter(w)}
+}
+
+// Writes a game, including its tags, comments, NAGs and variations,
+// followed by a blank line. Movetext lines are wrapped at 80 columns.
+func (w *Writer) WriteGame(g *Game) error {
+	w.w.WriteString(g.String())
+	w.w.WriteString("\n")
+	return w.w.Flush()
+}
+
+// Returns the game in PGN export format.
+func (g *Game) String() string {
+	var sb strings.Builder
+	for _, tag := range g.Tags {
+		sb.WriteString("[" + tag.Name + " \"" + escapeTagValue(tag.Value) + "\"]\n")
+	}
+	if len(g.Tags) != 0 {
+		sb.WriteString("\n")
+	}
+	var tokens []string
+	if g.Root.Comment != "" {
+		tokens = appendComment(tokens, g.Root.Comment)
+	}
+	tokens = appendLine(tokens, g.Root, true)
+	result := g.Result
+	if result == "" {
+		result = "*"
+	}
+	tokens = append(tokens, result)
+
+	lineLength := 0
+	for _, tok := range tokens {
+		if lineLength != 0 && lineLength+1+len(tok) > maxLineLength {
+			sb.WriteString("\n")
+			lineLength = 0
+		}
+		if lineLength != 0 {
+			sb.WriteString(" ")
+			lineLength++
+		}
+		sb.WriteString(tok)
+		lineLength += len(tok)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// Appends the tokens for the main line continuing from a node, along with all
+// of its variations. The first move always carries a move number if
+// forceNumber is set; otherwise, only white moves do.
+func appendLine(tokens []string, from *Node, forceNumber bool) []string {
+	for node := from; len(node.Children) != 0; node = node.Children[0] {
+		tokens = appendMove(tokens, node, node.Children[0], forceNumber)
+		forceNumber = false
+		for _, variation := range node.Children[1:] {
+			start := len(tokens)
+			if variation.StartingComment != "" {
+				tokens = appendComment(tokens, variation.StartingComment)
+			}
+			tokens = appendMove(tokens, node, variation, true)
+			tokens = appendLine(tokens, variation, false)
+			tokens[start] = "(" + tokens[start]
+			tokens[len(tokens)-1] += ")"
+			forceNumber = true
+		}
+		if node.Children[0].Comment != "" {
+			forceNumber = true
+		}
+	}
+	return tokens
+}
+
+// Appends the tokens for a single move, with its NAGs and comment.
+func appendMove(tokens []string, parent, child *Node, forceNumber bool) []string {
+	board := parent.board
+	moveNo := strconv.Itoa(int(board.Fullmoveno))
+	if board.Wtomove {
+		tokens = append(tokens, moveNo+".")
+	} else if forceNumber {
+		tokens = append(tokens, moveNo+"...")
+	}
+	tokens = append(tokens, board.MoveToSAN(child.Move))
+	for _, nag := range child.NAGs {
+		tokens = append(tokens, "$"+strconv.Itoa(nag))
+	}
+	if child.Comment != "" {
+		tokens = appendComment(tokens, child.Comment)
+	}
+	return tokens
+}
+
+// Appends a comment as one token per word, so it can be wrapped.
+func appendComment(tokens []string, comment string) []string {
+	words := strings.Fields(strings.Replace(comment, "}", "", -1))
+	if len(words) == 0 {
+		return tokens
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	return append(tokens, words...)
+}
+
+func escapeTagValue(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(s)
+}
@@ -0,0 +1,102 @@
+package pgn
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	for _, text := range []string{fischerSpassky, annotatedGames} {
+		r := NewReader(strings.NewReader(text))
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		var games []*Game
+		for {
+			g, err := r.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				continue // the deliberately illegal game
+			}
+			games = append(games, g)
+			if err := w.WriteGame(g); err != nil {
+				t.Fatal("Failed to write game:", err)
+			}
+		}
+		written := buf.String()
+		for _, line := range strings.Split(written, "\n") {
+			if len(line) > 80 && !strings.HasPrefix(line, "[") {
+				t.Error("Line exceeds 80 columns:", line)
+			}
+		}
+		// Reading and writing the output again should be lossless.
+		r = NewReader(strings.NewReader(written))
+		var rewritten bytes.Buffer
+		w = NewWriter(&rewritten)
+		for i := 0; ; i++ {
+			g, err := r.Next()
+			if err == io.EOF {
+				if i != len(games) {
+					t.Error("Expected", len(games), "games after a round trip, but got", i)
+				}
+				break
+			} else if err != nil {
+				t.Fatal("Failed to read written PGN:", err, "\n", written)
+			}
+			if g.String() != games[i].String() {
+				t.Error("Game changed during a round trip:\n", games[i].String(), "\n", g.String())
+			}
+			w.WriteGame(g)
+		}
+		if rewritten.String() != written {
+			t.Error("PGN changed during a round trip:\n", written, "\n", rewritten.String())
+		}
+	}
+}
+
+func TestWriteGame(t *testing.T) {
+	g := NewGame(dragontoothmg.ParseFen(dragontoothmg.Startpos))
+	g.SetTag("Event", "Test")
+	g.Result = "1-0"
+	b := g.Root.Board()
+	e4, _ := b.ParseSAN("e4")
+	d4, _ := b.ParseSAN("d4")
+	n := g.Root.AddChild(e4)
+	n.NAGs = []int{1}
+	g.Root.AddChild(d4).Comment = "Also good."
+	b = n.Board()
+	e5, _ := b.ParseSAN("e5")
+	n.AddChild(e5)
+	expected := "[Event \"Test\"]\n\n1. e4 $1 (1. d4 {Also good.}) 1... e5 1-0\n"
+	if g.String() != expected {
+		t.Error("Wrote the wrong PGN:\n", g.String(), "\nExpected:\n", expected)
+	}
+
+	g = NewGame(dragontoothmg.ParseFen("4k3/8/8/8/8/8/4P3/4K3 b - - 0 40"))
+	b = g.Root.Board()
+	kd7, _ := b.ParseSAN("Kd7")
+	g.Root.AddChild(kd7)
+	expected = "[SetUp \"1\"]\n[FEN \"4k3/8/8/8/8/8/4P3/4K3 b - - 0 40\"]\n\n40... Kd7 *\n"
+	if g.String() != expected {
+		t.Error("Wrote the wrong PGN:\n", g.String(), "\nExpected:\n", expected)
+	}
+
+	// Chess960 games keep their castling rights through the Variant tag
+	start, _ := dragontoothmg.ParseChess960Fen("rk6/8/8/8/8/8/8/RK6 w Qq - 0 1")
+	g = NewGame(start)
+	b = g.Root.Board()
+	castle, _ := b.ParseSAN("O-O-O")
+	g.Root.AddChild(castle)
+	expected = "[Variant \"Chess960\"]\n[SetUp \"1\"]\n[FEN \"rk6/8/8/8/8/8/8/RK6 w Qq - 0 1\"]\n\n1. O-O-O *\n"
+	if g.String() != expected {
+		t.Error("Wrote the wrong PGN:\n", g.String(), "\nExpected:\n", expected)
+	}
+	read, err := NewReader(strings.NewReader(g.String())).Next()
+	if err != nil || read.Root.Board() != start || read.String() != expected {
+		t.Error("Chess960 game changed during a round trip:", err, "\n", read)
+	}
+}
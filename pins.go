@@ -0,0 +1,109 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+// A piece that stands alone between a king and an enemy slider that would
+// otherwise attack the king.
+type Pin struct {
+	Pinned Square // the piece in between
+	Pinner Square // the rook, bishop or queen
+	Ray    uint64 // the squares between the king and the pinner, and the pinner itself
+}
+
+// Returns the pins against the king of the given color. A pinned piece can
+// only move along the ray, or not at all.
+func (b *Board) Pins(white bool) []Pin {
+	ours, theirs := &b.White, &b.Black
+	if !white {
+		ours, theirs = theirs, ours
+	}
+	pins := b.blockers(Square(bits.TrailingZeros64(ours.Kings)), theirs)
+	// Enemy pieces in the way only block, and don't pin
+	valid := pins[:0]
+	for _, pin := range pins {
+		if (uint64(1)<<pin.Pinned)&ours.All != 0 {
+			valid = append(valid, pin)
+		}
+	}
+	return valid
+}
+
+// Returns the pieces of the given color that are pinned to their king.
+func (b *Board) Pinned(white bool) uint64 {
+	var pinned uint64
+	for _, pin := range b.Pins(white) {
+		pinned |= uint64(1) << pin.Pinned
+	}
+	return pinned
+}
+
+// Returns the pieces that give check to the king of the side to move.
+func (b *Board) Checkers() uint64 {
+	ours, theirs := &b.White, &b.Black
+	if !b.Wtomove {
+		ours, theirs = theirs, ours
+	}
+	king := Square(bits.TrailingZeros64(ours.Kings))
+	return b.AttackersTo(king, b.White.All|b.Black.All) & theirs.All
+}
+
+// Returns the pieces of the side to move that stand alone between one of its
+// sliders and the opponent king, so that moving them off the ray gives check.
+func (b *Board) DiscoveredCheckCandidates() uint64 {
+	var candidates uint64
+	for _, pin := range b.DiscoveredChecks() {
+		candidates |= uint64(1) << pin.Pinned
+	}
+	return candidates
+}
+
+// Returns the potential discovered checks of the side to move: Pinned is the
+// piece that can move off the ray, and Pinner the slider behind it.
+func (b *Board) DiscoveredChecks() []Pin {
+	ours, theirs := &b.White, &b.Black
+	if !b.Wtomove {
+		ours, theirs = theirs, ours
+	}
+	pins := b.blockers(Square(bits.TrailingZeros64(theirs.Kings)), ours)
+	valid := pins[:0]
+	for _, pin := range pins {
+		if (uint64(1)<<pin.Pinned)&ours.All != 0 {
+			valid = append(valid, pin)
+		}
+	}
+	return valid
+}
+
+// Finds the pieces of either color that stand alone between a king and one of
+// the given sliders that share a rank, file or diagonal with it.
+func (b *Board) blockers(king Square, sliders *Bitboards) []Pin {
+	var pins []Pin
+	occupancy := b.White.All | b.Black.All
+	kingBitboard := uint64(1) << king
+	orthoSnipers := CalculateRookMoveBitboard(uint8(king), 0) & (sliders.Rooks | sliders.Queens)
+	diagSnipers := CalculateBishopMoveBitboard(uint8(king), 0) & (sliders.Bishops | sliders.Queens)
+	for snipers := orthoSnipers | diagSnipers; snipers != 0; snipers &= snipers - 1 {
+		sniper := uint8(bits.TrailingZeros64(snipers))
+		sniperBitboard := uint64(1) << sniper
+		// The rays of the king and the sniper, each blocked by the other, only
+		// meet between them
+		var between uint64
+		if orthoSnipers&sniperBitboard != 0 {
+			between = CalculateRookMoveBitboard(uint8(king), sniperBitboard) &
+				CalculateRookMoveBitboard(sniper, kingBitboard)
+		} else {
+			between = CalculateBishopMoveBitboard(uint8(king), sniperBitboard) &
+				CalculateBishopMoveBitboard(sniper, kingBitboard)
+		}
+		if blocker := between & occupancy; bits.OnesCount64(blocker) == 1 {
+			pins = append(pins, Pin{
+				Pinned: Square(bits.TrailingZeros64(blocker)),
+				Pinner: Square(sniper),
+				Ray:    between | sniperBitboard,
+			})
+		}
+	}
+	return pins
+}
@@ -0,0 +1,107 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+	"testing"
+)
+
+var pinPositions = []string{
+	Startpos,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"4k3/4r3/8/b7/8/2N5/4B3/q1P1K3 w - - 0 1",
+	"4k3/4r3/3N4/8/1B6/8/2Q5/4K2R b - - 0 1",
+	"4r3/8/8/4k3/3P4/8/1B2R3/4K3 b - - 0 1",
+}
+
+// Returns the pieces on a side whose removal would expose a king to more sliders
+func exposingPieces(b *Board, king Square, pieces uint64, sliders *Bitboards) uint64 {
+	occupancy := b.White.All | b.Black.All
+	attacking := func(occupancy uint64) uint64 {
+		return (CalculateRookMoveBitboard(uint8(king), occupancy) & (sliders.Rooks | sliders.Queens)) |
+			(CalculateBishopMoveBitboard(uint8(king), occupancy) & (sliders.Bishops | sliders.Queens))
+	}
+	var exposing uint64
+	for p := pieces &^ (b.White.Kings | b.Black.Kings); p != 0; p &= p - 1 {
+		piece := p & -p
+		if attacking(occupancy&^piece)&^attacking(occupancy)&^piece != 0 {
+			exposing |= piece
+		}
+	}
+	return exposing
+}
+
+func TestPinned(t *testing.T) {
+	for _, fen := range pinPositions {
+		b := ParseFen(fen)
+		for _, white := range []bool{true, false} {
+			ours, theirs := &b.White, &b.Black
+			if !white {
+				ours, theirs = theirs, ours
+			}
+			king := Square(bits.TrailingZeros64(ours.Kings))
+			expected := exposingPieces(&b, king, ours.All, theirs)
+			if pinned := b.Pinned(white); pinned != expected {
+				t.Error("Pinned pieces in", fen, "for white:", white, "are", squareNames(pinned),
+					"; expected", squareNames(expected))
+			}
+		}
+	}
+
+	b := ParseFen("4k3/4r3/8/b7/8/2N5/4B3/q1P1K3 w - - 0 1")
+	expected := map[string]Pin{
+		"e2": {Square(algebraicToIndexFatal("e2")), Square(algebraicToIndexFatal("e7")), 0},
+		"c3": {Square(algebraicToIndexFatal("c3")), Square(algebraicToIndexFatal("a5")), 0},
+		"c1": {Square(algebraicToIndexFatal("c1")), Square(algebraicToIndexFatal("a1")), 0},
+	}
+	pins := b.Pins(true)
+	if len(pins) != len(expected) {
+		t.Error("Pins are", pins, "; expected", expected)
+	}
+	for _, pin := range pins {
+		if e := expected[IndexToAlgebraic(pin.Pinned)]; pin.Pinner != e.Pinner {
+			t.Error("Piece on", IndexToAlgebraic(pin.Pinned), "is pinned by", IndexToAlgebraic(pin.Pinner))
+		}
+		if pin.Pinned == Square(algebraicToIndexFatal("c3")) && squareNames(pin.Ray) != "a5 b4 c3 d2" {
+			t.Error("Pin ray of the knight is", squareNames(pin.Ray))
+		}
+	}
+}
+
+func TestCheckers(t *testing.T) {
+	positions := map[string]string{
+		Startpos:                                "",
+		"4r3/8/8/4k3/3P4/8/1B2R3/4K3 b - - 0 1": "d4 e2",
+		"4k3/8/8/8/8/5n2/8/r3K3 w - - 0 1":      "a1 f3",
+		"4k3/8/8/8/8/8/8/r3K3 w - - 0 1":        "a1",
+		"4k3/8/8/8/8/8/8/rN2K3 w - - 0 1":       "",
+		"r3k2r/p1pp1pb1/bn2Qnp1/2qPN3/1p2P3/2N5/PPPBBPPP/R3K2R b KQkq - 3 2": "e6",
+	}
+	for fen, expected := range positions {
+		b := ParseFen(fen)
+		if checkers := squareNames(b.Checkers()); checkers != expected {
+			t.Error("Checkers in", fen, "are", checkers, "; expected", expected)
+		}
+	}
+}
+
+func TestDiscoveredCheckCandidates(t *testing.T) {
+	for _, fen := range pinPositions {
+		b := ParseFen(fen)
+		ours, theirs := &b.White, &b.Black
+		if !b.Wtomove {
+			ours, theirs = theirs, ours
+		}
+		king := Square(bits.TrailingZeros64(theirs.Kings))
+		expected := exposingPieces(&b, king, ours.All, ours)
+		if candidates := b.DiscoveredCheckCandidates(); candidates != expected {
+			t.Error("Discovered check candidates in", fen, "are", squareNames(candidates),
+				"; expected", squareNames(expected))
+		}
+	}
+	b := ParseFen("4k3/3N4/8/1B6/8/8/4R3/4K3 w - - 0 1")
+	if candidates := squareNames(b.DiscoveredCheckCandidates()); candidates != "d7" {
+		t.Error("Discovered check candidates are", candidates, "; expected d7")
+	}
+}
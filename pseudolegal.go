@@ -0,0 +1,148 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+// Generates all pseudo-legal moves for a given board: moves that follow the
+// movement rules of the pieces, but might leave our king in check. Castling and
+// en passant captures are only generated when they are legal. Filter the moves
+// with IsLegal before applying them.
+func (b *Board) GeneratePseudoLegalMoves() []Move {
+	moves := make([]Move, 0, kDefaultMoveListLength)
+	ourPieces := &(b.White)
+	if !b.Wtomove {
+		ourPieces = &(b.Black)
+	}
+	moves = b.pawnPushes(moves, everything, everything)
+	moves = b.pawnCaptures(moves, everything, everything)
+	moves = b.knightMoves(moves, everything, everything)
+	moves = b.rookMoves(moves, everything, everything)
+	moves = b.bishopMoves(moves, everything, everything)
+	moves = b.queenMoves(moves, everything, everything)
+	if !b.OurKingInCheck() {
+		moves = b.castlingMoves(moves, everything)
+	}
+	king := bits.TrailingZeros64(ourPieces.Kings)
+	return genMovesFromTargets(moves, Square(king), kingMasks[king]&^ourPieces.All)
+}
+
+// Determines whether a pseudo-legal move leaves our king safe, without applying
+// it. The result is undefined for moves that are not pseudo-legal, so check
+// moves from outside the move generator with IsPseudoLegal first.
+func (b *Board) IsLegal(m Move) bool {
+	ourPieces, oppPieces := &(b.White), &(b.Black)
+	epDelta := -8
+	if !b.Wtomove {
+		ourPieces, oppPieces = oppPieces, ourPieces
+		epDelta = 8
+	}
+	if b.isCastling(m) {
+		return b.isLegalCastling(m)
+	}
+	fromBitboard := uint64(1) << m.From()
+	toBitboard := uint64(1) << m.To()
+	king := Square(bits.TrailingZeros64(ourPieces.Kings))
+	if fromBitboard&ourPieces.Kings != 0 {
+		king = Square(m.To())
+	}
+	occupancy := (b.White.All|b.Black.All)&^fromBitboard | toBitboard
+	// The captured piece can't attack the king any more
+	captured := toBitboard
+	if fromBitboard&ourPieces.Pawns != 0 && m.To() == b.enpassant && b.enpassant != 0 {
+		captured = uint64(1) << (int(m.To()) + epDelta)
+		occupancy &^= captured
+	}
+	return b.AttackersTo(king, occupancy)&oppPieces.All&^captured == 0
+}
+
+// Determines whether a move, such as one from a transposition table or killer
+// slot, is pseudo-legal in this position. Moves that pass can be checked with
+// IsLegal, and then safely applied.
+func (b *Board) IsPseudoLegal(m Move) bool {
+	ourPieces, oppPieces := &(b.White), &(b.Black)
+	if !b.Wtomove {
+		ourPieces, oppPieces = oppPieces, ourPieces
+	}
+	fromBitboard := uint64(1) << m.From()
+	toBitboard := uint64(1) << m.To()
+	if ourPieces.All&fromBitboard == 0 {
+		return false
+	}
+	if b.isCastling(m) {
+		return m.Promote() == Nothing && b.isLegalCastling(m)
+	}
+	if ourPieces.All&toBitboard != 0 {
+		return false
+	}
+	occupancy := b.White.All | b.Black.All
+	pieceType, _ := determinePieceType(ourPieces, fromBitboard)
+	if pieceType == Pawn {
+		return b.pawnMoveIsPseudoLegal(m, oppPieces.All, occupancy)
+	}
+	if m.Promote() != Nothing {
+		return false
+	}
+	var targets uint64
+	switch pieceType {
+	case Knight:
+		targets = knightMasks[m.From()]
+	case Bishop:
+		targets = CalculateBishopMoveBitboard(uint8(m.From()), occupancy)
+	case Rook:
+		targets = CalculateRookMoveBitboard(uint8(m.From()), occupancy)
+	case Queen:
+		targets = CalculateBishopMoveBitboard(uint8(m.From()), occupancy) |
+			CalculateRookMoveBitboard(uint8(m.From()), occupancy)
+	case King:
+		targets = kingMasks[m.From()]
+	}
+	return targets&toBitboard != 0
+}
+
+// Determines whether a pawn move by the side to move is a valid push or capture,
+// with a promotion exactly when it reaches the last rank.
+func (b *Board) pawnMoveIsPseudoLegal(m Move, oppPieces uint64, occupancy uint64) bool {
+	from, to := int(m.From()), int(m.To())
+	toBitboard := uint64(1) << m.To()
+	forward, startRank, promotionRank := 8, onlyRank[1], onlyRank[7]
+	if !b.Wtomove {
+		forward, startRank, promotionRank = -8, onlyRank[6], onlyRank[0]
+	}
+	if toBitboard&promotionRank != 0 {
+		if m.Promote() < Knight || m.Promote() > Queen {
+			return false
+		}
+	} else if m.Promote() != Nothing {
+		return false
+	}
+	captureTargets := oppPieces
+	if b.enpassant != 0 {
+		captureTargets |= uint64(1) << b.enpassant
+	}
+	if PawnAttacks(Square(m.From()), b.Wtomove)&captureTargets&toBitboard != 0 {
+		return true
+	}
+	if toBitboard&occupancy != 0 {
+		return false
+	}
+	if to == from+forward {
+		return true
+	}
+	return to == from+2*forward && (uint64(1)<<m.From())&startRank != 0 &&
+		(uint64(1)<<(from+forward))&occupancy == 0
+}
+
+// Determines whether a castling move is among the legal castling moves.
+func (b *Board) isLegalCastling(m Move) bool {
+	if b.OurKingInCheck() {
+		return false
+	}
+	var buf [4]Move
+	for _, castle := range b.castlingMoves(buf[:0], everything) {
+		if castle == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+var pseudoLegalPositions = []string{
+	Startpos,
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	"8/8/8/2k5/2pP4/8/B7/4K3 b - d3 0 1",
+	"4k3/8/8/K2pP2q/8/8/8/8 w - d6 0 1",
+	"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+	"qnbnr1kr/ppp1b1pp/4p3/3p1p2/8/2NPP3/PPP1BPPP/QNB1R1KR w HEhe - 1 9",
+}
+
+// Checks that the legal moves are exactly the pseudo-legal moves that pass IsLegal
+func TestPseudoLegalMoves(t *testing.T) {
+	for _, fen := range pseudoLegalPositions {
+		b := ParseFen(fen)
+		checkPseudoLegalMoves(&b, 2, t)
+	}
+}
+
+func checkPseudoLegalMoves(b *Board, depth int, t *testing.T) {
+	legal := make(map[Move]bool)
+	for _, m := range b.GenerateLegalMoves() {
+		legal[m] = true
+	}
+	count := 0
+	for _, m := range b.GeneratePseudoLegalMoves() {
+		if !b.IsPseudoLegal(m) {
+			t.Error("Generated move", &m, "is not pseudo-legal in", b.ToFen())
+		}
+		if b.IsLegal(m) != legal[m] {
+			t.Error("IsLegal of", &m, "was", !legal[m], "in", b.ToFen())
+		}
+		if legal[m] {
+			count++
+		}
+	}
+	if count != len(legal) {
+		t.Error("Pseudo-legal moves include", count, "of", len(legal), "legal moves in", b.ToFen())
+	}
+	if depth == 0 {
+		return
+	}
+	for m := range legal {
+		unapply := b.Apply(m)
+		checkPseudoLegalMoves(b, depth-1, t)
+		unapply()
+	}
+}
+
+// Checks every possible move encoding against the legal moves
+func TestIsPseudoLegalAllMoves(t *testing.T) {
+	for _, fen := range pseudoLegalPositions {
+		b := ParseFen(fen)
+		checkAllMoves(&b, t)
+		for _, m := range b.GenerateLegalMoves() {
+			unapply := b.Apply(m)
+			checkAllMoves(&b, t)
+			unapply()
+		}
+	}
+}
+
+func checkAllMoves(b *Board, t *testing.T) {
+	legal := make(map[Move]bool)
+	for _, m := range b.GenerateLegalMoves() {
+		legal[m] = true
+	}
+	for from := Square(0); from < 64; from++ {
+		for to := Square(0); to < 64; to++ {
+			for _, promote := range []Piece{Nothing, Knight, Bishop, Rook, Queen} {
+				var m Move
+				m.Setfrom(from).Setto(to).Setpromote(promote)
+				if ok := b.IsPseudoLegal(m) && b.IsLegal(m); ok != legal[m] {
+					t.Error("Move", &m, "was accepted:", ok, "in", b.ToFen())
+				}
+			}
+		}
+	}
+}
+
+func TestIsPseudoLegal(t *testing.T) {
+	tests := []struct {
+		fen      string
+		move     string
+		expected bool
+	}{
+		{Startpos, "e2e4", true},
+		{Startpos, "e2e5", false},
+		{Startpos, "e7e5", false}, // not our piece
+		{Startpos, "e3e4", false}, // no piece
+		{Startpos, "g1e2", false}, // our own piece
+		{Startpos, "f1c4", false}, // blocked
+		{"4k3/8/8/8/8/4n3/4P3/4K3 w - - 0 1", "e2e4", false},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8", false},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8q", true},
+		{"4k3/8/8/8/8/8/1P6/4K3 w - - 0 1", "b2b3q", false},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", true},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - - 0 1", "e5d6", false},
+		{"4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", "e1g1", true},
+		{"4k3/8/8/8/8/8/8/R3K2R w Q - 0 1", "e1g1", false},
+		{"4kr2/8/8/8/8/8/8/R3K2R w KQ - 0 1", "e1g1", false}, // through check
+		{"4k3/8/8/8/8/8/5r2/4K3 w - - 0 1", "e1f1", true},    // pseudo-legal, but not legal
+	}
+	for _, test := range tests {
+		b := ParseFen(test.fen)
+		if result := b.IsPseudoLegal(parseMove(test.move)); result != test.expected {
+			t.Error("IsPseudoLegal of", test.move, "in", test.fen, "was", result, "; expected", test.expected)
+		}
+	}
+}
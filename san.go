@@ -0,0 +1,252 @@
+package dragontoothmg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Standard Algebraic Notation (SAN) support for moves.
+// Both directions depend on the position, so they are methods on Board.
+
+// The SAN letters for each piece type, indexed by Piece.
+var sanPieceLetters = [7]string{"", "", "N", "B", "R", "Q", "K"}
+
+// Converts a legal move to Standard Algebraic Notation (e.g. Nbd7, exd6, O-O-O, e8=Q+).
+// The move is disambiguated against the other legal moves in the position, and
+// suffixed with "+" or "#" if it gives check or checkmate.
+// The move must be legal in this position.
+func (b *Board) MoveToSAN(m Move) string {
+	var ourPieces *Bitboards
+	if b.Wtomove {
+		ourPieces = &(b.White)
+	} else {
+		ourPieces = &(b.Black)
+	}
+	pieceType, _ := determinePieceType(ourPieces, uint64(1)<<m.From())
+	var san string
+	if b.isCastling(m) && m.To() > m.From() {
+		san = "O-O"
+	} else if b.isCastling(m) {
+		san = "O-O-O"
+	} else {
+		capture := IsCapture(m, b)
+		if pieceType == Pawn {
+			if capture {
+				san = IndexToAlgebraic(Square(m.From()))[:1] + "x"
+			}
+			san += IndexToAlgebraic(Square(m.To()))
+			if m.Promote() != Nothing {
+				san += "=" + sanPieceLetters[m.Promote()]
+			}
+		} else {
+			san = sanPieceLetters[pieceType] + b.sanDisambiguation(m, pieceType, ourPieces)
+			if capture {
+				san += "x"
+			}
+			san += IndexToAlgebraic(Square(m.To()))
+		}
+	}
+	unapply := b.Apply(m)
+	if b.OurKingInCheck() {
+		if len(b.GenerateLegalMoves()) == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	unapply()
+	return san
+}
+
+// Computes the origin file and/or rank needed to distinguish a piece move from
+// legal moves by other pieces of the same type to the same square.
+func (b *Board) sanDisambiguation(m Move, pieceType Piece, ourPieces *Bitboards) string {
+	var sameFile, sameRank, ambiguous bool
+	for _, other := range b.GenerateLegalMoves() {
+		if other.To() != m.To() || other.From() == m.From() {
+			continue
+		}
+		otherType, _ := determinePieceType(ourPieces, uint64(1)<<other.From())
+		if otherType != pieceType {
+			continue
+		}
+		ambiguous = true
+		if other.From()%8 == m.From()%8 {
+			sameFile = true
+		}
+		if other.From()/8 == m.From()/8 {
+			sameRank = true
+		}
+	}
+	from := IndexToAlgebraic(Square(m.From()))
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return from[:1]
+	case !sameRank:
+		return from[1:]
+	default:
+		return from
+	}
+}
+
+// Parses a move in Standard Algebraic Notation, and finds the matching legal move.
+// The parser is forgiving: it accepts castling written with zeros (0-0), missing
+// or extra capture and hyphen markers (Nf7, exd6, Ng1-f3), lowercase piece and
+// promotion letters (nf3, e8=q, e8q), over-specified origins (Qa1b2), trailing
+// annotations (+, #, !, ?, e.p.), and long algebraic notation (e2e4).
+// Returns an error if the move is malformed, illegal, or ambiguous.
+func (b *Board) ParseSAN(san string) (Move, error) {
+	s := strings.TrimSpace(san)
+	s = strings.TrimSuffix(s, "e.p.")
+	s = strings.TrimRight(s, "+#!? ")
+	legalMoves := b.GenerateLegalMoves()
+
+	castle := strings.Replace(strings.ToUpper(s), "0", "O", -1)
+	if castle == "O-O" || castle == "O-O-O" {
+		kingside := castle == "O-O"
+		for _, mv := range legalMoves {
+			if b.isCastling(mv) && kingside == (mv.To() > mv.From()) {
+				return mv, nil
+			}
+		}
+		return 0, fmt.Errorf("Illegal SAN move %q", san)
+	}
+
+	// Split off the promotion piece: e8=Q, e8Q, or e8q
+	var promote Piece = Nothing
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		if i != len(s)-2 {
+			return 0, fmt.Errorf("Invalid SAN move %q", san)
+		}
+		promote = sanPromotionPiece(s[i+1])
+		if promote == Nothing {
+			return 0, fmt.Errorf("Invalid promotion piece in SAN move %q", san)
+		}
+		s = s[:i]
+	} else if len(s) >= 3 && (s[len(s)-2] == '1' || s[len(s)-2] == '8') {
+		if p := sanPromotionPiece(s[len(s)-1]); p != Nothing {
+			promote = p
+			s = s[:len(s)-1]
+		}
+	}
+	s = strings.NewReplacer("x", "", "X", "", ":", "", "-", "").Replace(s)
+	if len(s) < 2 {
+		return 0, fmt.Errorf("Invalid SAN move %q", san)
+	}
+
+	// A lowercase 'b' might be a bishop or a pawn on the b-file. Try the pawn first.
+	var candidates []Piece
+	switch s[0] {
+	case 'N', 'n':
+		candidates = []Piece{Knight}
+	case 'B':
+		candidates = []Piece{Bishop}
+	case 'b':
+		candidates = []Piece{Pawn, Bishop}
+	case 'R', 'r':
+		candidates = []Piece{Rook}
+	case 'Q', 'q':
+		candidates = []Piece{Queen}
+	case 'K', 'k':
+		candidates = []Piece{King}
+	default:
+		candidates = []Piece{Pawn}
+	}
+	for _, pieceType := range candidates {
+		body := s
+		if pieceType != Pawn {
+			body = s[1:]
+		}
+		mv, found, err := b.matchSAN(legalMoves, pieceType, body, promote)
+		if err != nil {
+			return 0, fmt.Errorf("%v in SAN move %q", err, san)
+		}
+		if found {
+			return mv, nil
+		}
+	}
+
+	// Finally, fall back to long algebraic notation.
+	if mv, err := ParseMove(strings.TrimSpace(san)); err == nil {
+		for _, legal := range legalMoves {
+			if legal == mv {
+				return mv, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("Illegal SAN move %q", san)
+}
+
+// Finds the unique legal move for a piece type, given the rest of a SAN move
+// (an optional origin file and rank, and a destination square).
+// Reports whether a move was found, or an error if the move is ambiguous.
+func (b *Board) matchSAN(legalMoves []Move, pieceType Piece, body string, promote Piece) (Move, bool, error) {
+	if len(body) < 2 || len(body) > 4 {
+		return 0, false, nil
+	}
+	to, err := AlgebraicToIndex(body[len(body)-2:])
+	if err != nil {
+		return 0, false, nil
+	}
+	fromFile, fromRank := -1, -1
+	for _, c := range strings.ToLower(body[:len(body)-2]) {
+		switch {
+		case c >= 'a' && c <= 'h' && fromFile == -1 && fromRank == -1:
+			fromFile = int(c - 'a')
+		case c >= '1' && c <= '8' && fromRank == -1:
+			fromRank = int(c - '1')
+		default:
+			return 0, false, nil
+		}
+	}
+	// Pawns only change files when capturing, which SAN always marks with a file.
+	if pieceType == Pawn && fromFile == -1 {
+		fromFile = int(to % 8)
+	}
+	var ourPieces *Bitboards
+	if b.Wtomove {
+		ourPieces = &(b.White)
+	} else {
+		ourPieces = &(b.Black)
+	}
+	var result Move
+	matches := 0
+	for _, mv := range legalMoves {
+		if mv.To() != to || mv.Promote() != promote {
+			continue
+		}
+		if (fromFile != -1 && int(mv.From()%8) != fromFile) || (fromRank != -1 && int(mv.From()/8) != fromRank) {
+			continue
+		}
+		if p, _ := determinePieceType(ourPieces, uint64(1)<<mv.From()); p != pieceType {
+			continue
+		}
+		// Castling is only written as O-O or O-O-O
+		if pieceType == King && b.isCastling(mv) {
+			continue
+		}
+		result = mv
+		matches++
+	}
+	if matches > 1 {
+		return 0, false, fmt.Errorf("Ambiguous move")
+	}
+	return result, matches == 1, nil
+}
+
+// Converts a promotion letter, in either case, to a piece type.
+func sanPromotionPiece(c byte) Piece {
+	switch c {
+	case 'N', 'n':
+		return Knight
+	case 'B', 'b':
+		return Bishop
+	case 'R', 'r':
+		return Rook
+	case 'Q', 'q':
+		return Queen
+	}
+	return Nothing
+}
@@ -0,0 +1,145 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestMoveToSAN(t *testing.T) {
+	// Each test case is a position, a move in long algebraic notation, and its SAN
+	tests := []struct {
+		fen  string
+		move string
+		san  string
+	}{
+		{Startpos, "g1f3", "Nf3"},
+		{Startpos, "e2e4", "e4"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "e1g1", "O-O"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "e1c1", "O-O-O"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R b KQkq - 0 1", "e8c8", "O-O-O"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "d5e6", "dxe6"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "e5f7", "Nxf7"},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "e2a6", "Bxa6"},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "e5f6", "exf6"},
+		{"4k3/8/8/8/8/8/8/1N1NK3 w - - 0 1", "b1c3", "Nbc3"},
+		{"4k3/8/8/8/8/8/8/1N1NK3 w - - 0 1", "d1c3", "Ndc3"},
+		{"4k3/8/8/1N6/8/8/8/1N2K3 w - - 0 1", "b1a3", "N1a3"},
+		{"4k3/8/8/1N6/8/8/8/1N2K3 w - - 0 1", "b5a3", "N5a3"},
+		{"4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "a1b2", "Qa1b2"},
+		{"4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "c1b2", "Qcb2"},
+		{"4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "a3b2", "Q3b2"},
+		{"4k3/4r3/8/8/8/8/N3N3/4K3 w - - 0 1", "a2c3", "Nc3"}, // the other knight is pinned
+		{"8/P6k/8/8/8/8/8/K7 w - - 0 1", "a7a8q", "a8=Q"},
+		{"8/P6k/8/8/8/8/8/K7 w - - 0 1", "a7a8n", "a8=N"},
+		{"2k5/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7e8q", "e8=Q+"},
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", "h5f7", "Qxf7#"},
+		{"k7/8/8/8/8/8/p7/7K b - - 0 1", "a2a1q", "a1=Q+"},
+	}
+	for _, test := range tests {
+		b := ParseFen(test.fen)
+		fenBefore := b.ToFen()
+		mv := parseMove(test.move)
+		san := b.MoveToSAN(mv)
+		if san != test.san {
+			t.Error("Wrong SAN for", test.move, "in", test.fen, "\nExpected", test.san, "but got", san)
+		}
+		if b.ToFen() != fenBefore {
+			t.Error("SAN conversion corrupted board state for", test.fen)
+		}
+		parsed, err := b.ParseSAN(san)
+		if err != nil || parsed != mv {
+			t.Error("SAN round trip failed for", san, "in", test.fen, "\nGot", &parsed, err)
+		}
+	}
+}
+
+func TestParseSAN(t *testing.T) {
+	kiwipete := "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
+	pawnOrBishop := "4k3/8/8/8/8/2p5/1P1B4/4K3 w - - 0 1"
+	// Each test case is a position, some sloppy SAN, and the move it should parse to
+	tests := []struct {
+		fen  string
+		san  string
+		move string
+	}{
+		{Startpos, "Nf3", "g1f3"},
+		{Startpos, "nf3", "g1f3"},
+		{Startpos, "Ng1-f3", "g1f3"},
+		{Startpos, "Ng1f3", "g1f3"},
+		{Startpos, "e4", "e2e4"},
+		{Startpos, "e2e4", "e2e4"},
+		{Startpos, "e2-e4", "e2e4"},
+		{Startpos, "g1f3", "g1f3"},
+		{kiwipete, "0-0", "e1g1"},
+		{kiwipete, "O-O+", "e1g1"},
+		{kiwipete, "o-o-o", "e1c1"},
+		{kiwipete, "0-0-0", "e1c1"},
+		{kiwipete, "Nf7", "e5f7"},
+		{kiwipete, "Nxf7!?", "e5f7"},
+		{kiwipete, "de6", "d5e6"},
+		{kiwipete, "d5xe6", "d5e6"},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "exf6 e.p.", "e5f6"},
+		{pawnOrBishop, "bxc3", "b2c3"},
+		{pawnOrBishop, "bc3", "b2c3"},
+		{pawnOrBishop, "Bxc3", "d2c3"},
+		{pawnOrBishop, "Bc3", "d2c3"},
+		{pawnOrBishop, "b3", "b2b3"},
+		{pawnOrBishop, "be3", "d2e3"},
+		{"8/P6k/8/8/8/8/8/K7 w - - 0 1", "a8=Q", "a7a8q"},
+		{"8/P6k/8/8/8/8/8/K7 w - - 0 1", "a8=q", "a7a8q"},
+		{"8/P6k/8/8/8/8/8/K7 w - - 0 1", "a8N", "a7a8n"},
+		{"8/P6k/8/8/8/8/8/K7 w - - 0 1", "a8r", "a7a8r"},
+		{"4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", "Qa1xb2", "a1b2"},
+		{"r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4", "Qxf7#", "h5f7"},
+	}
+	for _, test := range tests {
+		b := ParseFen(test.fen)
+		mv, err := b.ParseSAN(test.san)
+		if err != nil {
+			t.Error("Failed to parse SAN", test.san, "in", test.fen, "\n", err)
+		} else if mv != parseMove(test.move) {
+			t.Error("Parsed SAN", test.san, "in", test.fen, "as", &mv, "instead of", test.move)
+		}
+	}
+
+	invalid := map[string]string{
+		"Nf6":  Startpos,                            // illegal
+		"e5":   Startpos,                            // illegal
+		"Ke2":  Startpos,                            // illegal
+		"O-O":  Startpos,                            // illegal
+		"Nc3":  "4k3/8/8/8/8/8/8/1N1NK3 w - - 0 1",  // ambiguous
+		"a8":   "8/P6k/8/8/8/8/8/K7 w - - 0 1",      // promotion required
+		"a8=K": "8/P6k/8/8/8/8/8/K7 w - - 0 1",      // invalid promotion
+		"Qb2":  "4k3/8/8/8/8/Q7/8/Q1Q1K3 w - - 0 1", // ambiguous
+		"":     Startpos,
+		"xyz":  Startpos,
+		"Zf3":  Startpos,
+	}
+	for san, fen := range invalid {
+		b := ParseFen(fen)
+		if mv, err := b.ParseSAN(san); err == nil {
+			t.Error("Parsed invalid SAN", san, "in", fen, "as", &mv)
+		}
+	}
+}
+
+// Every legal move in a variety of positions should survive a SAN round trip.
+func TestSANRoundTrip(t *testing.T) {
+	positions := []string{
+		Startpos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+	}
+	for _, fen := range positions {
+		b := ParseFen(fen)
+		for _, mv := range b.GenerateLegalMoves() {
+			san := b.MoveToSAN(mv)
+			parsed, err := b.ParseSAN(san)
+			if err != nil || parsed != mv {
+				t.Error("SAN round trip failed for", &mv, "as", san, "in", fen, "\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+// Piece values for static exchange evaluation, indexed by piece type. Engines
+// may use their own values, to match their evaluation; attackers are always
+// tried from pawns up to the king, whatever their values.
+type SEEValues [King + 1]int
+
+// The piece values used by Board.SEE and Board.SEEGreaterOrEqual.
+var defaultSEEValues = SEEValues{
+	Nothing: 0,
+	Pawn:    100,
+	Knight:  320,
+	Bishop:  330,
+	Rook:    500,
+	Queen:   900,
+	King:    20000,
+}
+
+// Returns the piece values used by Board.SEE, as a starting point for an
+// engine's own values.
+func DefaultSEEValues() SEEValues {
+	return defaultSEEValues
+}
+
+// Computes the static exchange evaluation of a move: the material balance,
+// from the point of view of the side to move, after the sequence of captures
+// on the target square in which each side captures with its least valuable
+// piece, or stops when continuing would lose material. Pins are ignored, and
+// castling moves evaluate to zero.
+func (b *Board) SEE(m Move) int {
+	return defaultSEEValues.SEE(b, m)
+}
+
+// Determines whether the static exchange evaluation of a move is at least
+// the threshold. This is faster than computing it with SEE, since the
+// exchange stops as soon as the outcome is known.
+func (b *Board) SEEGreaterOrEqual(m Move, threshold int) bool {
+	return defaultSEEValues.SEEGreaterOrEqual(b, m, threshold)
+}
+
+// Computes the static exchange evaluation of a move like Board.SEE, with
+// these piece values.
+func (values *SEEValues) SEE(b *Board, m Move) int {
+	var gain [32]int
+	if b.isCastling(m) {
+		return 0
+	}
+	to := m.To()
+	value, onSquare, occupancy := b.seeFirstCapture(m, values)
+	gain[0] = value
+	attackers := b.AttackersTo(Square(to), occupancy) & occupancy
+	white := !b.Wtomove
+	depth := 0
+	for {
+		ours, theirs := &b.Black, &b.White
+		if white {
+			ours, theirs = theirs, ours
+		}
+		square, piece := leastValuableAttacker(attackers&ours.All, ours)
+		if piece == Nothing {
+			break
+		}
+		if piece == King && attackers&theirs.All != 0 {
+			break // the king cannot capture a defended piece
+		}
+		depth++
+		gain[depth] = values[onSquare] - gain[depth-1]
+		onSquare = piece
+		occupancy &^= uint64(1) << square
+		attackers |= b.seeXrays(to, occupancy)
+		attackers &= occupancy
+		white = !white
+	}
+	// Either side may stop capturing, if that leaves it better off
+	for ; depth > 0; depth-- {
+		if gain[depth] > -gain[depth-1] {
+			gain[depth-1] = -gain[depth]
+		}
+	}
+	return gain[0]
+}
+
+// Determines whether the static exchange evaluation of a move is at least the
+// threshold like Board.SEEGreaterOrEqual, with these piece values.
+func (values *SEEValues) SEEGreaterOrEqual(b *Board, m Move, threshold int) bool {
+	if b.isCastling(m) {
+		return 0 >= threshold
+	}
+	to := m.To()
+	value, onSquare, occupancy := b.seeFirstCapture(m, values)
+	// The balance the side to move needs to keep, after each capture
+	swap := value - threshold
+	if swap < 0 {
+		return false
+	}
+	swap = values[onSquare] - swap
+	if swap <= 0 {
+		return true
+	}
+	attackers := b.AttackersTo(Square(to), occupancy)
+	white := !b.Wtomove
+	result := 1
+	for {
+		attackers &= occupancy
+		ours, theirs := &b.Black, &b.White
+		if white {
+			ours, theirs = theirs, ours
+		}
+		square, piece := leastValuableAttacker(attackers&ours.All, ours)
+		if piece == Nothing {
+			break
+		}
+		result ^= 1
+		if piece == King {
+			// The king can only capture if the opponent has no attackers left
+			if attackers&theirs.All != 0 {
+				return result^1 == 1
+			}
+			return result == 1
+		}
+		swap = values[piece] - swap
+		if swap < result {
+			break
+		}
+		occupancy &^= uint64(1) << square
+		attackers |= b.seeXrays(to, occupancy)
+		white = !white
+	}
+	return result == 1
+}
+
+// Returns the value captured by a move (including the gain of a promotion),
+// the piece it leaves on the target square, and the occupancy after it.
+func (b *Board) seeFirstCapture(m Move, values *SEEValues) (int, Piece, uint64) {
+	ours, theirs := &b.White, &b.Black
+	epDelta := -8
+	if !b.Wtomove {
+		ours, theirs = theirs, ours
+		epDelta = 8
+	}
+	fromBitboard := uint64(1) << m.From()
+	toBitboard := uint64(1) << m.To()
+	occupancy := (b.White.All | b.Black.All) &^ fromBitboard
+	piece, _ := determinePieceType(ours, fromBitboard)
+	captured, _ := determinePieceType(theirs, toBitboard)
+	if piece == Pawn && m.To() == b.enpassant && b.enpassant != 0 {
+		captured = Pawn
+		occupancy &^= uint64(1) << uint8(int(b.enpassant)+epDelta)
+	}
+	value := values[captured]
+	if m.Promote() != Nothing {
+		value += values[m.Promote()] - values[Pawn]
+		piece = m.Promote()
+	}
+	return value, piece, occupancy
+}
+
+// Returns the sliders of both sides that attack a square through the given
+// occupancy, to find the attackers uncovered as pieces are exchanged.
+func (b *Board) seeXrays(square uint8, occupancy uint64) uint64 {
+	diagSliders := b.White.Bishops | b.White.Queens | b.Black.Bishops | b.Black.Queens
+	orthoSliders := b.White.Rooks | b.White.Queens | b.Black.Rooks | b.Black.Queens
+	return (CalculateBishopMoveBitboard(square, occupancy) & diagSliders) |
+		(CalculateRookMoveBitboard(square, occupancy) & orthoSliders)
+}
+
+// Returns the square and type of the least valuable of the given attackers.
+func leastValuableAttacker(attackers uint64, side *Bitboards) (uint8, Piece) {
+	for piece, bitboard := range [...]uint64{side.Pawns, side.Knights, side.Bishops, side.Rooks, side.Queens, side.Kings} {
+		if attackers&bitboard != 0 {
+			return uint8(bits.TrailingZeros64(attackers & bitboard)), Piece(piece + Pawn)
+		}
+	}
+	return 0, Nothing
+}
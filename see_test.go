@@ -0,0 +1,81 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestSEE(t *testing.T) {
+	positions := []struct {
+		fen  string
+		move string
+		see  int
+	}{
+		{"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", 100},
+		{"1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", "d3e5", -220},
+		{"4k3/8/2p5/3p4/8/8/8/3QK3 w - - 0 1", "d1d5", -800},
+		{"3rk3/3r4/8/8/3p4/8/3R4/3RK3 w - - 0 1", "d2d4", -400},
+		{"3rk3/8/8/8/3p4/8/3R4/3RK3 w - - 0 1", "d2d4", 100},   // the rook behind recaptures
+		{"4k3/8/4p3/3p4/8/1B6/Q7/4K3 w - - 0 1", "b3d5", -130}, // the queen is behind the bishop
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 100},
+		{"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8q", 800},
+		{"r3k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7b8q", -100},
+		{"r3k3/1P6/8/8/8/8/8/4K3 w - - 0 1", "b7a8n", 720},
+		{"4k3/8/8/8/8/8/3p4/4K3 w - - 0 1", "e1d2", 100},
+		{"4k3/8/8/4p3/3p4/8/8/3RK3 w - - 0 1", "d1d4", -400},
+		{"4k3/8/8/8/3p4/4K3/8/8 w - - 0 1", "e3d4", 100},
+		{"4k3/8/8/4n3/3p4/4K3/8/8 w - - 0 1", "e3e4", 0}, // quiet, and not attacked
+		{"4k3/8/8/8/8/8/8/R3K2R w KQ - 0 1", "e1g1", 0},
+	}
+	for _, p := range positions {
+		b := ParseFen(p.fen)
+		m := parseMove(p.move)
+		if see := b.SEE(m); see != p.see {
+			t.Error("SEE of", p.move, "in", p.fen, "is", see, "; expected", p.see)
+		}
+		if !b.SEEGreaterOrEqual(m, p.see) || b.SEEGreaterOrEqual(m, p.see+1) {
+			t.Error("SEEGreaterOrEqual disagrees with SEE for", p.move, "in", p.fen)
+		}
+	}
+}
+
+func TestSEEValues(t *testing.T) {
+	// Bishop takes knight, and pawn takes bishop
+	b := ParseFen("4k3/8/2p5/3n4/8/8/6B1/4K3 w - - 0 1")
+	m := parseMove("g2d5")
+	values := DefaultSEEValues()
+	values[Knight], values[Bishop] = 300, 350
+	if see := values.SEE(&b, m); see != -50 || !values.SEEGreaterOrEqual(&b, m, -50) || values.SEEGreaterOrEqual(&b, m, -49) {
+		t.Error("SEE with custom values is", see, "; expected -50")
+	}
+	// The defaults are unchanged
+	if see := b.SEE(m); see != -10 || DefaultSEEValues()[Knight] != 320 {
+		t.Error("SEE with the default values is", see, "; expected -10")
+	}
+}
+
+// Checks that SEEGreaterOrEqual agrees with SEE for every legal move
+func TestSEEGreaterOrEqual(t *testing.T) {
+	positions := []string{
+		Startpos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10",
+		"1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1",
+		"2r2rk1/1bqnbppp/p2ppn2/1p6/3NP3/1BN1BP2/PPPQ2PP/2KR3R b - - 0 1",
+	}
+	for _, fen := range positions {
+		b := ParseFen(fen)
+		for _, m := range b.GenerateLegalMoves() {
+			see := b.SEE(m)
+			for threshold := -1000; threshold <= 1000; threshold += 10 {
+				if b.SEEGreaterOrEqual(m, threshold) != (see >= threshold) {
+					t.Error("SEEGreaterOrEqual of", m.String(), "in", fen, "at", threshold,
+						"disagrees with SEE", see)
+					break
+				}
+			}
+		}
+	}
+}
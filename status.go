@@ -0,0 +1,77 @@
+package dragontoothmg
+
+import (
+	"math/bits"
+)
+
+// The status of the game in a given position, as returned by Board.Status().
+type Status uint8
+
+const (
+	Ongoing              Status = iota
+	Checkmate            Status = iota // the side to move has been checkmated
+	Stalemate            Status = iota // the side to move has no legal moves, but is not in check
+	FiftyMoveClaimable   Status = iota // either side may claim a draw under the fifty-move rule
+	SeventyFiveMoveDraw  Status = iota // the game is drawn under the seventy-five-move rule
+	InsufficientMaterial Status = iota // neither side can possibly checkmate
+)
+
+// Returns a human-readable name for the status.
+func (s Status) String() string {
+	switch s {
+	case Ongoing:
+		return "Ongoing"
+	case Checkmate:
+		return "Checkmate"
+	case Stalemate:
+		return "Stalemate"
+	case FiftyMoveClaimable:
+		return "FiftyMoveClaimable"
+	case SeventyFiveMoveDraw:
+		return "SeventyFiveMoveDraw"
+	case InsufficientMaterial:
+		return "InsufficientMaterial"
+	}
+	return "Unknown"
+}
+
+// Determines whether the game has ended in the current position.
+// Checkmate and stalemate take precedence over the other rules. The halfmove
+// clock counts plies, so the fifty-move rule applies from 100, and the
+// seventy-five-move rule from 150. Repetitions require the game history, and
+// are not detected here.
+func (b *Board) Status() Status {
+	var buf [kMaxMoveListLength]Move
+	if len(b.GenerateLegalMovesInto(buf[:0])) == 0 {
+		if b.OurKingInCheck() {
+			return Checkmate
+		}
+		return Stalemate
+	}
+	if b.InsufficientMaterial() {
+		return InsufficientMaterial
+	}
+	if b.Halfmoveclock >= 150 {
+		return SeventyFiveMoveDraw
+	}
+	if b.Halfmoveclock >= 100 {
+		return FiftyMoveClaimable
+	}
+	return Ongoing
+}
+
+// Determines whether neither side can checkmate by any series of legal moves,
+// under the FIDE dead-position rules: K vs K, KB vs K, KN vs K, and positions
+// where all bishops stand on squares of the same color.
+func (b *Board) InsufficientMaterial() bool {
+	if b.White.Pawns|b.Black.Pawns|b.White.Rooks|b.Black.Rooks|b.White.Queens|b.Black.Queens != 0 {
+		return false
+	}
+	knights := b.White.Knights | b.Black.Knights
+	bishops := b.White.Bishops | b.Black.Bishops
+	if knights != 0 {
+		return bits.OnesCount64(knights) == 1 && bishops == 0
+	}
+	const darkSquares uint64 = 0xAA55AA55AA55AA55
+	return bishops&darkSquares == 0 || bishops&^darkSquares == 0
+}
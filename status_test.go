@@ -0,0 +1,36 @@
+package dragontoothmg
+
+import (
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	positions := map[string]Status{
+		Startpos: Ongoing,
+		"5k1R/5p2/5P2/8/8/2r5/2rR2K1/4B3 b - - 0 1":                     Checkmate,
+		"rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3": Checkmate,
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1":                                Stalemate,
+		"k7/8/8/8/8/8/8/7K w - - 0 1":                                   InsufficientMaterial,
+		"k7/8/8/8/8/8/8/6BK w - - 0 1":                                  InsufficientMaterial,
+		"k7/8/8/8/8/8/8/6NK w - - 0 1":                                  InsufficientMaterial,
+		"kb6/8/8/8/8/8/8/6BK w - - 0 1":                                 InsufficientMaterial, // same-colored bishops
+		"kb6/8/8/8/8/8/8/B1B4K w - - 0 1":                               InsufficientMaterial,
+		"k1b5/8/8/8/8/8/8/6BK w - - 0 1":                                Ongoing, // opposite-colored bishops
+		"kn6/8/8/8/8/8/8/6NK w - - 0 1":                                 Ongoing,
+		"k7/8/8/8/8/8/8/5BNK w - - 0 1":                                 Ongoing,
+		"k7/8/8/8/8/8/8/5NNK w - - 0 1":                                 Ongoing,
+		"k7/8/8/8/8/8/8/6PK w - - 0 1":                                  Ongoing,
+		"k7/8/8/8/8/8/8/6RK w - - 99 80":                                Ongoing,
+		"k7/8/8/8/8/8/8/6RK w - - 100 80":                               FiftyMoveClaimable,
+		"k7/8/8/8/8/8/8/6RK w - - 149 80":                               FiftyMoveClaimable,
+		"k7/8/8/8/8/8/8/6RK w - - 150 80":                               SeventyFiveMoveDraw,
+		"k7/8/8/8/8/8/8/6BK w - - 150 80":                               InsufficientMaterial,
+		"R6k/6pp/8/8/8/8/8/K7 b - - 150 80":                             Checkmate, // mate takes precedence
+	}
+	for fen, expected := range positions {
+		b := ParseFen(fen)
+		if status := b.Status(); status != expected {
+			t.Error("Status of", fen, "was", status, "; expected", expected)
+		}
+	}
+}
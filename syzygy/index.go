@@ -0,0 +1,125 @@
+package syzygy
+
+// Tables used to map positions to indices in a tablebase file. A position is
+// first mirrored so that its leading piece or pawn is on the queenside (and,
+// without pawns, in the a1-d1-d4 triangle), then each group of pieces is
+// encoded as a combination of the squares left over by the previous groups.
+var (
+	mapPawns      [64]int     // squares a2-h7, numbered 0..47 from the edges inward
+	mapB1H1H7     [64]int     // squares below the a1-h8 diagonal, numbered 0..27
+	mapA1D1D4     [64]int     // squares in the a1-d1-d4 triangle, numbered 0..9
+	mapKK         [10][64]int // the 462 placements of two kings, by mapA1D1D4 of the first
+	binomial      [6][64]uint64
+	leadPawnIdx   [6][64]uint64 // [lead pawn count][square of the leading pawn]
+	leadPawnsSize [6][4]uint64  // [lead pawn count][file of the leading pawn]
+)
+
+// The number of ways to place the leading group of a pawnless table, when it
+// is made of three unique pieces or only of the two kings.
+const (
+	uniquePiecesSize = 31332
+	kingPairSize     = 462
+)
+
+// Returns the distance of a square above the a1-h8 diagonal (negative if below).
+func offA1H8(sq int) int {
+	return sq>>3 - sq&7
+}
+
+func init() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offA1H8(sq) < 0 {
+			mapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	// Squares on the diagonal are numbered last
+	code = 0
+	var diagonal []int
+	for sq := 0; sq <= 27; sq++ { // a1 to d4
+		if sq&7 > 3 {
+			continue
+		}
+		if offA1H8(sq) < 0 {
+			mapA1D1D4[sq] = code
+			code++
+		} else if offA1H8(sq) == 0 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		mapA1D1D4[sq] = code
+		code++
+	}
+
+	// If the first king is on the a1-d4 diagonal, the second may not be above
+	// the a1-h8 diagonal. Placements with both kings on the diagonal are last.
+	type kingPair struct{ idx, sq int }
+	var bothOnDiagonal []kingPair
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for s1 := 0; s1 <= 27; s1++ {
+			if s1&7 > 3 || offA1H8(s1) > 0 || mapA1D1D4[s1] != idx {
+				continue
+			}
+			for s2 := 0; s2 < 64; s2++ {
+				if abs(s1>>3-s2>>3) <= 1 && abs(s1&7-s2&7) <= 1 {
+					continue // adjacent kings
+				} else if offA1H8(s1) == 0 && offA1H8(s2) > 0 {
+					continue
+				} else if offA1H8(s1) == 0 && offA1H8(s2) == 0 {
+					bothOnDiagonal = append(bothOnDiagonal, kingPair{idx, s2})
+				} else {
+					mapKK[idx][s2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, p := range bothOnDiagonal {
+		mapKK[p.idx][p.sq] = code
+		code++
+	}
+
+	binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				binomial[k][n] += binomial[k-1][n-1]
+			}
+			if k < n {
+				binomial[k][n] += binomial[k][n-1]
+			}
+		}
+	}
+
+	// The leading pawn is the one with the highest mapPawns value: nearest the
+	// edge, and then on the lowest rank. Other pawns may only be on squares
+	// with lower values.
+	available := 47
+	for leadPawns := 1; leadPawns <= 5; leadPawns++ {
+		for file := 0; file < 4; file++ {
+			var idx uint64
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				if leadPawns == 1 {
+					mapPawns[sq] = available
+					mapPawns[sq^7] = available - 1
+					available -= 2
+				}
+				leadPawnIdx[leadPawns][sq] = idx
+				idx += binomial[leadPawns-1][mapPawns[sq]]
+			}
+			leadPawnsSize[leadPawns][file] = idx
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
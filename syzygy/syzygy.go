@@ -0,0 +1,588 @@
+// Package syzygy probes Syzygy endgame tablebases.
+//
+// A Tablebase reads the .rtbw (win/draw/loss) and .rtbz (distance to zeroing)
+// files in a directory, loading each one the first time it is needed. Probes
+// resolve captures with a small search, since the tables do not store
+// positions where a capture or en passant is the best move. Repetitions are
+// not known to the board, so they are not taken into account.
+package syzygy
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// A WDL is the result of a position for the side to move, with perfect play.
+type WDL int
+
+const (
+	Loss        WDL = -2 // the side to move loses
+	BlessedLoss WDL = -1 // a loss, but the fifty-move rule makes it a draw
+	Draw        WDL = 0
+	CursedWin   WDL = 1 // a win, but the fifty-move rule makes it a draw
+	Win         WDL = 2 // the side to move wins
+)
+
+func (w WDL) String() string {
+	switch w {
+	case Loss:
+		return "loss"
+	case BlessedLoss:
+		return "blessed loss"
+	case Draw:
+		return "draw"
+	case CursedWin:
+		return "cursed win"
+	case Win:
+		return "win"
+	}
+	return fmt.Sprintf("WDL(%d)", int(w))
+}
+
+// A Tablebase is a directory of Syzygy tables. It is safe for concurrent use.
+type Tablebase struct {
+	dir       string
+	mu        sync.Mutex        // guards the loading of tables
+	tables    map[string]*table // by file name, e.g. "KRvK.rtbw"
+	maxPieces int
+}
+
+// Opens the tables in a directory. The files are only read when a probe
+// needs them.
+func Open(dir string) (*Tablebase, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	tb := &Tablebase{dir: dir, tables: make(map[string]*table)}
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if ext != ".rtbw" && ext != ".rtbz" {
+			continue
+		}
+		t, err := newTable(strings.TrimSuffix(f.Name(), ext), ext == ".rtbz")
+		if err != nil {
+			continue // not a Syzygy table
+		}
+		tb.tables[f.Name()] = t
+		if !t.dtz && t.pieceCount > tb.maxPieces {
+			tb.maxPieces = t.pieceCount
+		}
+	}
+	return tb, nil
+}
+
+// Returns the largest number of pieces, including kings, of the WDL tables.
+func (tb *Tablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// Returns the result of a position for the side to move, ignoring the
+// fifty-move counter (which only distinguishes cursed wins from wins).
+func (tb *Tablebase) ProbeWDL(b *dragontoothmg.Board) (WDL, error) {
+	if err := tb.check(b); err != nil {
+		return Draw, err
+	}
+	pos := *b
+	wdl, _, err := tb.search(&pos, false)
+	return wdl, err
+}
+
+// Returns the distance to zeroing of a position, in plies: the number of plies
+// until the next capture or pawn move, with the winning side making progress
+// as fast as possible and the losing side delaying it as long as possible.
+// The result is positive if the side to move wins, negative if it loses, and
+// zero for a draw. Cursed wins and blessed losses are offset by 100 plies.
+// The result may be off by one ply from the true distance, since the tables
+// do not always store it exactly.
+func (tb *Tablebase) ProbeDTZ(b *dragontoothmg.Board) (int, error) {
+	if err := tb.check(b); err != nil {
+		return 0, err
+	}
+	pos := *b
+	return tb.probeDTZ(&pos)
+}
+
+// Returns the legal moves that preserve the best result of the position. Among
+// winning moves, those that win before the fifty-move rule are preferred, and
+// among losing moves, those that hold a draw by the fifty-move rule, or
+// otherwise delay the loss the longest. Unlike Stockfish's root probing,
+// repetitions are ignored, since the board has no history of earlier positions.
+func (tb *Tablebase) RootProbe(b *dragontoothmg.Board) ([]dragontoothmg.Move, error) {
+	if err := tb.check(b); err != nil {
+		return nil, err
+	}
+	const maxDTZ = 1 << 18
+	pos := *b
+	cnt50 := int(pos.Halfmoveclock)
+	moves := pos.GenerateLegalMoves()
+	ranks := make([]int, len(moves))
+	bestRank := -maxDTZ - 1
+	for i, m := range moves {
+		zeroing := isZeroing(&pos, m)
+		undo := pos.MakeMove(m)
+		var dtz int
+		var err error
+		status := pos.Status()
+		if zeroing {
+			var wdl WDL
+			wdl, _, err = tb.search(&pos, false)
+			dtz = dtzBeforeZeroing(-wdl)
+		} else if pos.Halfmoveclock >= 100 && status != dragontoothmg.Checkmate {
+			dtz = 0
+		} else {
+			dtz, err = tb.probeDTZ(&pos)
+			dtz = -dtz
+			if dtz > 0 {
+				dtz++
+			} else if dtz < 0 {
+				dtz--
+			}
+		}
+		if status == dragontoothmg.Checkmate && dtz == 2 {
+			dtz = 1
+		}
+		pos.UnmakeMove(m, undo)
+		if err != nil {
+			return nil, err
+		}
+
+		// Wins within the fifty-move rule rank equally, then the faster cursed
+		// wins. Losses rank by their length, below those that the fifty-move
+		// rule can save.
+		switch {
+		case dtz > 0 && dtz+cnt50 <= 99:
+			ranks[i] = maxDTZ
+		case dtz > 0:
+			ranks[i] = maxDTZ - (dtz + cnt50)
+		case dtz < 0 && -dtz*2+cnt50 < 100:
+			ranks[i] = -maxDTZ - dtz
+		case dtz < 0:
+			ranks[i] = -maxDTZ + (-dtz + cnt50)
+		}
+		if ranks[i] > bestRank {
+			bestRank = ranks[i]
+		}
+	}
+	var best []dragontoothmg.Move
+	for i, m := range moves {
+		if ranks[i] == bestRank {
+			best = append(best, m)
+		}
+	}
+	return best, nil
+}
+
+// Checks that a position can be probed.
+func (tb *Tablebase) check(b *dragontoothmg.Board) error {
+	if b.HasCastlingRights() {
+		return fmt.Errorf("syzygy: positions with castling rights are not in the tables")
+	}
+	if n := bits.OnesCount64(b.White.All | b.Black.All); n > 2 && n > tb.maxPieces {
+		return fmt.Errorf("syzygy: %d pieces exceed the %d-piece tables", n, tb.maxPieces)
+	}
+	return nil
+}
+
+type probeState int
+
+const (
+	stateOK              probeState = iota
+	stateZeroingBestMove            // the best move is a capture or pawn move
+	stateChangeSTM                  // the DTZ table stores the other side to move
+)
+
+// Returns the table for the material of a position, loading it if needed.
+// The second result is whether the table stores the position with the colors
+// reversed.
+func (tb *Tablebase) table(b *dragontoothmg.Board, dtz bool) (*table, bool, error) {
+	white, black := material(&b.White), material(&b.Black)
+	ext := ".rtbw"
+	if dtz {
+		ext = ".rtbz"
+	}
+	blackStronger := false
+	t := tb.tables[white+"v"+black+ext]
+	if t == nil {
+		t = tb.tables[black+"v"+white+ext]
+		blackStronger = true
+	}
+	if t == nil {
+		return nil, false, fmt.Errorf("syzygy: missing table %sv%s%s", white, black, ext)
+	}
+	tb.mu.Lock()
+	err := t.load(tb.dir)
+	tb.mu.Unlock()
+	return t, blackStronger, err
+}
+
+// Returns the pieces of one side as in table names, e.g. "KRP".
+func material(bb *dragontoothmg.Bitboards) string {
+	var sb strings.Builder
+	sb.WriteByte('K')
+	for _, p := range []struct {
+		bitboard uint64
+		letter   string
+	}{{bb.Queens, "Q"}, {bb.Rooks, "R"}, {bb.Bishops, "B"}, {bb.Knights, "N"}, {bb.Pawns, "P"}} {
+		sb.WriteString(strings.Repeat(p.letter, bits.OnesCount64(p.bitboard)))
+	}
+	return sb.String()
+}
+
+// Looks up a position in its WDL or DTZ table, without resolving captures.
+// For DTZ tables, the result of the position must be given.
+func (tb *Tablebase) probeTable(b *dragontoothmg.Board, dtz bool, wdl WDL) (int, probeState, error) {
+	if bits.OnesCount64(b.White.All|b.Black.All) == 2 {
+		return int(Draw), stateOK, nil // KvK
+	}
+	t, blackStronger, err := tb.table(b, dtz)
+	if err != nil {
+		return 0, stateOK, err
+	}
+	value, state := t.probe(b, blackStronger, wdl)
+	return value, state, nil
+}
+
+// Returns the stored value of a position.
+func (t *table) probe(b *dragontoothmg.Board, blackStronger bool, wdl WDL) (int, probeState) {
+	d, file, idx, state := t.encode(b, blackStronger)
+	if state == stateChangeSTM {
+		return 0, state
+	}
+	return t.mapScore(file, d.decompress(t.data, idx), wdl), stateOK
+}
+
+// Maps a position to a subtable, given by the file of the leading pawn, and
+// to an index in it.
+func (t *table) encode(b *dragontoothmg.Board, blackStronger bool) (*pairsData, int, uint64, probeState) {
+	// Piece codes are the piece type, plus 8 for black
+	var board [64]int
+	for color, bb := range []*dragontoothmg.Bitboards{&b.White, &b.Black} {
+		for piece, pieces := range []uint64{bb.Pawns, bb.Knights, bb.Bishops, bb.Rooks, bb.Queens, bb.Kings} {
+			for ; pieces != 0; pieces &= pieces - 1 {
+				board[bits.TrailingZeros64(pieces)] = piece + dragontoothmg.Pawn + 8*color
+			}
+		}
+	}
+
+	// Tables store positions with the stronger side as white, and symmetric
+	// tables only store white to move, so swap the colors otherwise.
+	side := 0
+	if !b.Wtomove {
+		side = 1
+	}
+	flip := blackStronger || (t.symmetric && side == 1)
+	flipColor, flipSquares, stm := 0, 0, side
+	if flip {
+		flipColor, flipSquares, stm = 8, 56, side^1
+	}
+
+	// Pawn tables are split by the file of the leading pawn, which is the one
+	// with the highest mapPawns value.
+	var squares, pieces [maxPieces]int
+	size, leadPawnCount, tbFile := 0, 0, 0
+	var leadPawns uint64
+	if t.hasPawns {
+		leadPawns = b.White.Pawns
+		if t.items[0][0].pieces[0]^flipColor >= 8 {
+			leadPawns = b.Black.Pawns
+		}
+		for p := leadPawns; p != 0; p &= p - 1 {
+			squares[size] = bits.TrailingZeros64(p) ^ flipSquares
+			size++
+		}
+		leadPawnCount = size
+		lead := 0
+		for i := 1; i < leadPawnCount; i++ {
+			if mapPawns[squares[i]] > mapPawns[squares[lead]] {
+				lead = i
+			}
+		}
+		squares[0], squares[lead] = squares[lead], squares[0]
+		tbFile = squares[0] & 7
+		if tbFile > 3 {
+			tbFile = 7 - tbFile
+		}
+	}
+
+	// DTZ tables only store one side to move, except for symmetric pawnless tables
+	if t.dtz && int(t.get(stm, tbFile).flags&flagSTM) != stm && !(t.symmetric && !t.hasPawns) {
+		return nil, tbFile, 0, stateChangeSTM
+	}
+
+	for sq := 0; sq < 64; sq++ {
+		if board[sq] != 0 && leadPawns&(uint64(1)<<uint(sq)) == 0 {
+			squares[size] = sq ^ flipSquares
+			pieces[size] = board[sq] ^ flipColor
+			size++
+		}
+	}
+	d := t.get(stm, tbFile)
+
+	// Order the pieces as the table encodes them
+	for i := leadPawnCount; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// Mirror the board so that the leading piece is on files a-d
+	if squares[0]&7 > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx uint64
+	if t.hasPawns {
+		idx = leadPawnIdx[leadPawnCount][squares[0]]
+		others := squares[1:leadPawnCount]
+		sort.SliceStable(others, func(i, j int) bool { return mapPawns[others[i]] < mapPawns[others[j]] })
+		for i := 1; i < leadPawnCount; i++ {
+			idx += binomial[i][mapPawns[squares[i]]]
+		}
+	} else {
+		// Without pawns, also mirror the leading piece to ranks 1-4, and then
+		// below the a1-h8 diagonal, unless the whole leading group is on it.
+		if squares[0]>>3 > 3 {
+			for i := 0; i < size; i++ {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < d.groupLen[0]; i++ {
+			if offA1H8(squares[i]) == 0 {
+				continue
+			}
+			if offA1H8(squares[i]) > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+				}
+			}
+			break
+		}
+		if t.hasUniquePieces {
+			idx = uint64(uniquePiecesIndex(squares[0], squares[1], squares[2]))
+		} else {
+			idx = uint64(mapKK[mapA1D1D4[squares[0]]][squares[1]])
+		}
+	}
+	idx *= d.groupIdx[0]
+
+	// Encode the other groups as combinations of the squares that the previous
+	// groups leave free. The other side's pawns can't be on ranks 1 or 8.
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	start := d.groupLen[0]
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+		var n uint64
+		for i, sq := range group {
+			adjust := 0
+			for _, s := range squares[:start] {
+				if sq > s {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += binomial[i+1][sq-adjust]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += d.groupLen[next]
+	}
+	return d, tbFile, idx, stateOK
+}
+
+// Returns the index of three unique pieces, the first of which is in the
+// a1-d1-d4 triangle and the first not on the a1-h8 diagonal is below it.
+func uniquePiecesIndex(s0, s1, s2 int) int {
+	adjust1, adjust2 := 0, 0
+	if s1 > s0 {
+		adjust1++
+	}
+	if s2 > s0 {
+		adjust2++
+	}
+	if s2 > s1 {
+		adjust2++
+	}
+	switch {
+	case offA1H8(s0) != 0:
+		return (mapA1D1D4[s0]*63+s1-adjust1)*62 + s2 - adjust2
+	case offA1H8(s1) != 0:
+		return (6*63+(s0>>3)*28+mapB1H1H7[s1])*62 + s2 - adjust2
+	case offA1H8(s2) != 0:
+		return 6*63*62 + 4*28*62 + (s0>>3)*7*28 + (s1>>3-adjust1)*28 + mapB1H1H7[s2]
+	default:
+		return 6*63*62 + 4*28*62 + 4*7*28 + (s0>>3)*7*6 + (s1>>3-adjust1)*6 + s2>>3 - adjust2
+	}
+}
+
+// Converts a stored value to a WDL, or to a DTZ in plies.
+func (t *table) mapScore(file int, value int, wdl WDL) int {
+	if !t.dtz {
+		return value - 2
+	}
+	d := t.get(0, file)
+	if d.flags&flagMapped != 0 {
+		m := d.mapIdx[[]int{1, 3, 0, 2, 0}[wdl+2]]
+		if d.flags&flagWide != 0 {
+			value = int(t.data[m+2*value]) | int(t.data[m+2*value+1])<<8
+		} else {
+			value = int(t.data[m+value])
+		}
+	}
+	if (wdl == Win && d.flags&flagWinPlies == 0) || (wdl == Loss && d.flags&flagLossPlies == 0) ||
+		wdl == CursedWin || wdl == BlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
+
+// Returns the result of a position, searching captures (and, if checkZeroing
+// is set, pawn moves) to find the positions where they are the best move,
+// since the tables may store any value there.
+func (tb *Tablebase) search(b *dragontoothmg.Board, checkZeroing bool) (WDL, probeState, error) {
+	moves := b.GenerateLegalMoves()
+	best := Loss
+	searched := 0
+	for _, m := range moves {
+		if !dragontoothmg.IsCapture(m, b) && (!checkZeroing || !isPawnMove(b, m)) {
+			continue
+		}
+		searched++
+		undo := b.MakeMove(m)
+		value, _, err := tb.search(b, false)
+		b.UnmakeMove(m, undo)
+		if err != nil {
+			return Draw, stateOK, err
+		}
+		if -value > best {
+			best = -value
+			if best >= Win {
+				return best, stateZeroingBestMove, nil
+			}
+		}
+	}
+
+	// If every legal move was searched, the table is not needed (and may be
+	// wrong, as with en passant)
+	noMoreMoves := searched > 0 && searched == len(moves)
+	value := best
+	if !noMoreMoves {
+		stored, _, err := tb.probeTable(b, false, Draw)
+		if err != nil {
+			return Draw, stateOK, err
+		}
+		value = WDL(stored)
+	}
+	if best >= value {
+		if best > Draw || noMoreMoves {
+			return best, stateZeroingBestMove, nil
+		}
+		return best, stateOK, nil
+	}
+	return value, stateOK, nil
+}
+
+func (tb *Tablebase) probeDTZ(b *dragontoothmg.Board) (int, error) {
+	wdl, state, err := tb.search(b, true)
+	if err != nil || wdl == Draw {
+		return 0, err
+	}
+	if state == stateZeroingBestMove {
+		return dtzBeforeZeroing(wdl), nil
+	}
+	dtz, state, err := tb.probeTable(b, true, wdl)
+	if err != nil {
+		return 0, err
+	}
+	if state != stateChangeSTM {
+		if wdl == BlessedLoss || wdl == CursedWin {
+			dtz += 100
+		}
+		if wdl < Draw {
+			dtz = -dtz
+		}
+		return dtz, nil
+	}
+
+	// The table stores the other side to move, so search one ply for the
+	// move with the smallest DTZ that keeps the result
+	minDTZ := 0xFFFF
+	for _, m := range b.GenerateLegalMoves() {
+		zeroing := isZeroing(b, m)
+		undo := b.MakeMove(m)
+		if zeroing {
+			var value WDL
+			value, _, err = tb.search(b, false)
+			dtz = -dtzBeforeZeroing(value)
+		} else {
+			dtz, err = tb.probeDTZ(b)
+			dtz = -dtz
+		}
+		if dtz == 1 && b.Status() == dragontoothmg.Checkmate {
+			minDTZ = 1
+		}
+		b.UnmakeMove(m, undo)
+		if err != nil {
+			return 0, err
+		}
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < minDTZ && sign(dtz) == sign(int(wdl)) {
+			minDTZ = dtz
+		}
+	}
+	if minDTZ == 0xFFFF {
+		return -1, nil // checkmated
+	}
+	return minDTZ, nil
+}
+
+// Returns the DTZ of a position in which the best move is a zeroing move.
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case Win:
+		return 1
+	case CursedWin:
+		return 101
+	case BlessedLoss:
+		return -101
+	case Loss:
+		return -1
+	}
+	return 0
+}
+
+func isPawnMove(b *dragontoothmg.Board, m dragontoothmg.Move) bool {
+	return (b.White.Pawns|b.Black.Pawns)&(uint64(1)<<m.From()) != 0
+}
+
+// Determines whether a move resets the fifty-move counter.
+func isZeroing(b *dragontoothmg.Board, m dragontoothmg.Move) bool {
+	return dragontoothmg.IsCapture(m, b) || isPawnMove(b, m)
+}
+
+func sign(x int) int {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,226 @@
+package syzygy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// Writes a KQvK table in which every position has the same value. The sizes
+// hold the flags and value of each subtable.
+func writeKQvK(t *testing.T, dir string, dtz bool, sizes ...byte) {
+	magic := wdlMagic
+	ext := ".rtbw"
+	if dtz {
+		magic, ext = dtzMagic, ".rtbz"
+	}
+	data := append(magic[:], 0x01, 0x00) // split, no pawns; order of the groups
+	data = append(data, 0x66, 0x55, 0xEE, 0x00)
+	data = append(data, sizes...)
+	data = append(data, make([]byte, 64-len(data))...)
+	if err := os.WriteFile(filepath.Join(dir, "KQvK"+ext), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProbeWDL(t *testing.T) {
+	dir := t.TempDir()
+	writeKQvK(t, dir, false, flagSingleValue, byte(Win+2), flagSingleValue, byte(Loss+2))
+	if err := os.WriteFile(filepath.Join(dir, "KRvK.rtbw"), make([]byte, 64), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tb, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tb.MaxPieces() != 3 {
+		t.Error("MaxPieces is", tb.MaxPieces())
+	}
+	expected := map[string]WDL{
+		"8/8/8/8/8/2k5/8/1Q5K w - - 0 1": Win,
+		"8/8/8/8/8/2k5/8/1Q5K b - - 0 1": Loss,
+		"8/8/8/8/8/2k5/1Q6/7K b - - 0 1": Draw, // the king captures the queen
+		"8/8/8/8/8/2K5/8/1q5k b - - 0 1": Win,
+		"8/8/8/8/8/2K5/8/1q5k w - - 0 1": Loss,
+		"8/8/8/8/8/2K5/1q6/7k w - - 0 1": Draw,
+		"8/8/8/4k3/8/8/8/4K3 w - - 0 1":  Draw,
+	}
+	for fen, wdl := range expected {
+		b := dragontoothmg.ParseFen(fen)
+		if result, err := tb.ProbeWDL(&b); err != nil || result != wdl {
+			t.Error("WDL of", fen, "is", result, err, "; expected", wdl)
+		}
+	}
+
+	errors := map[string]string{
+		"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1":  "castling",
+		"4k3/8/8/8/8/8/8/RN2K3 w - - 0 1": "4 pieces",
+		"4k3/8/8/8/8/8/8/B3K3 w - - 0 1":  "missing",
+		"4k3/8/8/8/8/8/8/R3K3 w - - 0 1":  "KRvK.rtbw",
+	}
+	for fen, message := range errors {
+		b := dragontoothmg.ParseFen(fen)
+		if _, err := tb.ProbeWDL(&b); err == nil || !strings.Contains(err.Error(), message) {
+			t.Error("Probing", fen, "returned", err, "; expected an error about", message)
+		}
+	}
+	b := dragontoothmg.ParseFen("8/8/8/8/8/2k5/8/1Q5K w - - 0 1")
+	if _, err := tb.ProbeDTZ(&b); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Error("Probing DTZ without the DTZ table returned", err)
+	}
+}
+
+func TestProbeDTZ(t *testing.T) {
+	dir := t.TempDir()
+	writeKQvK(t, dir, false, flagSingleValue, byte(Win+2), flagSingleValue, byte(Loss+2))
+	// Stores 5 moves for white to move, which is 10 or 11 plies
+	writeKQvK(t, dir, true, flagSingleValue, 5)
+	tb, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{
+		"8/8/8/8/8/2k5/8/1Q5K w - - 0 1": 11,
+		"8/8/8/8/8/2k5/8/1Q5K b - - 0 1": -12,
+		"8/8/8/8/8/2k5/1Q6/7K b - - 0 1": 0,
+		"k6Q/8/1K6/8/8/8/8/8 b - - 0 1":  -1,
+	}
+	for fen, dtz := range expected {
+		b := dragontoothmg.ParseFen(fen)
+		if result, err := tb.ProbeDTZ(&b); err != nil || result != dtz {
+			t.Error("DTZ of", fen, "is", result, err, "; expected", dtz)
+		}
+	}
+
+	// Close to the fifty-move rule, only the mate still wins
+	b := dragontoothmg.ParseFen("k7/8/1K6/8/8/8/7Q/8 w - - 98 60")
+	moves, err := tb.RootProbe(&b)
+	var result []string
+	for _, m := range moves {
+		result = append(result, m.String())
+	}
+	sort.Strings(result)
+	if err != nil || strings.Join(result, " ") != "h2h8" {
+		t.Error("Root moves were", result, err, "; expected h2h8")
+	}
+}
+
+// Probes the official tables in the directory given by the SYZYGY_PATH
+// environment variable, which are not kept in the repository. The
+// expected values follow from the positions themselves: mates, stalemates,
+// forced trades down to bare kings, and textbook pawn endings. The positions
+// of a table are skipped if its files are missing.
+func TestRealTables(t *testing.T) {
+	dir := os.Getenv("SYZYGY_PATH")
+	if dir == "" {
+		t.Skip("SYZYGY_PATH is not set")
+	}
+	tb, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		table string
+		fen   string
+		wdl   WDL
+		dtz   int // zero when only the sign is known
+	}{
+		{"KQvK", "8/8/8/8/8/2k5/8/1Q5K w - - 0 1", Win, 0},
+		{"KQvK", "8/8/8/8/8/2K5/8/1q5k b - - 0 1", Win, 0},
+		{"KQvK", "8/8/8/8/8/2k5/1Q6/7K b - - 0 1", Draw, 0}, // the king captures the queen
+		{"KQvK", "k7/8/1K6/8/8/8/7Q/8 w - - 0 1", Win, 1},   // Qh8 mates
+		{"KQvK", "k6Q/8/1K6/8/8/8/8/8 b - - 0 1", Loss, -1},
+		{"KQvK", "k7/3Q4/1K6/8/8/8/8/8 b - - 0 1", Loss, -2}, // Kb8 Qb7
+		{"KRvK", "8/8/8/8/8/3k4/8/R3K3 b - - 0 1", Loss, 0},
+		{"KRvK", "k7/8/1K6/8/8/8/8/7R w - - 0 1", Win, 1},
+		{"KRvK", "k7/8/2K5/8/8/8/8/7R w - - 0 1", Win, 3}, // Kb6 Kb8 Rh8
+		{"KNvK", "8/8/8/4k3/8/8/8/3NK3 w - - 0 1", Draw, 0},
+		{"KPvK", "8/4P3/8/8/8/k7/8/4K3 w - - 0 1", Win, 1},
+		{"KPvK", "8/4P3/8/8/8/k7/8/4K3 b - - 0 1", Loss, -2},
+		{"KPvK", "4k3/4P3/4K3/8/8/8/8/8 b - - 0 1", Draw, 0}, // stalemate
+		{"KPvK", "8/4k3/8/4K3/4P3/8/8/8 w - - 0 1", Draw, 0}, // black has the opposition
+		{"KPvK", "8/4k3/8/4K3/4P3/8/8/8 b - - 0 1", Loss, 0},
+		{"KPvK", "k7/8/8/8/8/8/P7/K7 w - - 0 1", Draw, 0},
+		// Symmetric tables store white to move, so black to move is flipped
+		{"KRvKR", "7k/8/6K1/8/8/8/7r/R7 w - - 0 1", Win, 1}, // Ra8 mates
+		{"KRvKR", "r7/7R/8/8/8/6k1/8/7K b - - 0 1", Win, 1},
+		{"KRvKR", "R6k/8/6K1/8/8/8/7r/8 b - - 0 1", Loss, -1},
+		{"KRvKR", "8/8/8/8/R7/K7/8/rk6 w - - 0 1", Draw, 0},
+		{"KRvKR", "RK6/8/k7/r7/8/8/8/8 b - - 0 1", Draw, 0},
+		// Both sides have pawns
+		{"KPvKP", "6k1/4P3/3p2K1/8/8/8/8/8 w - - 0 1", Win, 1}, // e8=Q mates
+		{"KPvKP", "6k1/4P3/3p2K1/8/8/8/8/8 b - - 0 1", Loss, -2},
+		{"KPvKP", "8/8/8/8/8/3P2k1/4p3/6K1 b - - 0 1", Win, 1},
+		{"KPvKP", "8/8/8/8/8/3P2k1/4p3/6K1 w - - 0 1", Loss, -2},
+		{"KPvKP", "7K/p4k1P/8/8/8/8/8/8 w - - 0 1", Draw, 0}, // stalemate
+		// Two leading pawns
+		{"KPPvK", "k7/6P1/1K3P2/8/8/8/8/8 w - - 0 1", Win, 1}, // g8=Q mates
+		{"KPPvK", "k7/6P1/1K3P2/8/8/8/8/8 b - - 0 1", Loss, -2},
+		{"KPPvK", "8/8/8/8/8/1k3p2/6p1/K7 w - - 0 1", Loss, -2},
+		{"KPPvK", "k7/P7/1K6/8/8/8/8/7P b - - 0 1", Draw, 0}, // stalemate
+	}
+	missing := map[string]bool{}
+	for _, name := range []string{"KQvK", "KRvK", "KNvK", "KPvK", "KRvKR", "KPvKP", "KPPvK"} {
+		for _, ext := range []string{".rtbw", ".rtbz"} {
+			if _, err := os.Stat(filepath.Join(dir, name+ext)); err != nil {
+				t.Log("Missing table", name+ext)
+				missing[name] = true
+			}
+		}
+	}
+	for _, test := range tests {
+		if missing[test.table] {
+			continue
+		}
+		b := dragontoothmg.ParseFen(test.fen)
+		if wdl, err := tb.ProbeWDL(&b); err != nil || wdl != test.wdl {
+			t.Error("WDL of", test.fen, "is", wdl, err, "; expected", test.wdl)
+		}
+		switch dtz, err := tb.ProbeDTZ(&b); {
+		case err != nil:
+			t.Error("Probing DTZ of", test.fen, "returned", err)
+		case test.dtz == 0 && sign(dtz) != sign(int(test.wdl)):
+			t.Error("DTZ of", test.fen, "is", dtz, "; expected the sign of a", test.wdl)
+		case test.dtz != 0 && !dtzMatches(dtz, test.dtz):
+			t.Error("DTZ of", test.fen, "is", dtz, "; expected", test.dtz)
+		}
+	}
+	if missing["KQvK"] || missing["KRvK"] {
+		return
+	}
+
+	// Winning root moves keep the win, and losing ones put off the loss
+	b := dragontoothmg.ParseFen("k7/8/1K6/8/8/8/7Q/8 w - - 0 1")
+	moves, err := tb.RootProbe(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range moves {
+		unapply := b.Apply(m)
+		if result, err := tb.ProbeWDL(&b); err != nil || result != Loss {
+			t.Error("Root move", m.String(), "does not win")
+		}
+		unapply()
+	}
+	b = dragontoothmg.ParseFen("8/8/8/3k4/8/8/8/R3K3 b - - 0 1")
+	moves, err = tb.RootProbe(&b)
+	var result []string
+	for _, m := range moves {
+		result = append(result, m.String())
+	}
+	sort.Strings(result)
+	// Ke4 is the only move that loses faster, in 25 plies instead of 27
+	if expected := "d5c4 d5c5 d5c6 d5d4 d5d6 d5e5 d5e6"; err != nil || strings.Join(result, " ") != expected {
+		t.Error("Root moves were", result, err, "; expected", expected)
+	}
+}
+
+// Reports whether a probed DTZ matches the true distance. As ProbeDTZ
+// documents, the tables may give a distance one ply shorter.
+func dtzMatches(dtz, expected int) bool {
+	return dtz == expected || abs(expected) > 1 && dtz == expected-sign(expected)
+}
@@ -0,0 +1,443 @@
+package syzygy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxPieces = 7
+
+var (
+	wdlMagic = [4]byte{0x71, 0xE8, 0x23, 0x5D}
+	dtzMagic = [4]byte{0xD7, 0x66, 0x0C, 0xA5}
+)
+
+// Flags of each pairsData record
+const (
+	flagSTM         = 1   // DTZ tables: the side to move stored in the table
+	flagMapped      = 2   // DTZ tables: values are mapped through the DTZ map
+	flagWinPlies    = 4   // DTZ tables: wins are stored in plies, not moves
+	flagLossPlies   = 8   // DTZ tables: losses are stored in plies, not moves
+	flagWide        = 16  // DTZ tables: the DTZ map has 16-bit values
+	flagSingleValue = 128 // every position in the table has the same value
+)
+
+// A pairsData holds the information needed to decompress one subtable: the
+// positions with a given side to move, and a given file of the leading pawn.
+// Offsets are into the data of the table file.
+type pairsData struct {
+	flags           byte
+	minSymLen       int // for single-value tables, the value itself
+	maxSymLen       int
+	blockSize       int
+	span            uint64 // there is a sparse index entry every span values
+	numBlocks       int
+	lowestSym       int // offset: lowest symbol of each length
+	btree           int // offset: the pair of symbols that each symbol expands to
+	blockLength     int // offset: the number of values, minus one, in each block
+	blockLengthSize int
+	sparseIndex     int // offset: block and offset of every span'th value
+	sparseIndexSize int
+	data            int      // offset: the Huffman-coded blocks
+	base64          []uint64 // the lowest code of each length, left-aligned to 64 bits
+	symlen          []int    // the number of values, minus one, that each symbol expands to
+
+	pieces   [maxPieces]int     // the order in which pieces are encoded
+	groupLen [maxPieces + 1]int // the number of pieces in each group, zero-terminated
+	groupIdx [maxPieces + 1]uint64
+	mapIdx   [4]int // DTZ tables: offsets of the DTZ map for win, loss, cursed win, blessed loss
+}
+
+// A table is one .rtbw or .rtbz file, loaded on first use.
+type table struct {
+	name            string // e.g. "KRvK"
+	dtz             bool
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool   // some piece other than a king appears only once for its color
+	symmetric       bool   // both sides have the same pieces
+	pawnCount       [2]int // pawns of the leading color, then of the other color
+
+	loaded bool
+	err    error
+	data   []byte
+	items  [2][4]pairsData // [side to move][file of the leading pawn]
+}
+
+// Creates a table for a name like "KRPvKR". The file is not read.
+func newTable(name string, dtz bool) (*table, error) {
+	sides := strings.Split(name, "v")
+	if len(sides) != 2 || len(name) > maxPieces+1 {
+		return nil, fmt.Errorf("syzygy: invalid table name %q", name)
+	}
+	t := &table{name: name, dtz: dtz, symmetric: sides[0] == sides[1]}
+	var pawns [2]int
+	for color, side := range sides {
+		if !strings.HasPrefix(side, "K") || strings.Count(side, "K") != 1 {
+			return nil, fmt.Errorf("syzygy: invalid table name %q", name)
+		}
+		for _, c := range side {
+			if !strings.ContainsRune("KQRBNP", c) {
+				return nil, fmt.Errorf("syzygy: invalid table name %q", name)
+			}
+			if c != 'K' && strings.Count(side, string(c)) == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+		pawns[color] = strings.Count(side, "P")
+		t.pieceCount += len(side)
+	}
+	t.hasPawns = pawns[0]+pawns[1] > 0
+	// The leading color is the one with fewer pawns, but at least one
+	if pawns[1] == 0 || (pawns[0] != 0 && pawns[1] >= pawns[0]) {
+		t.pawnCount = pawns
+	} else {
+		t.pawnCount = [2]int{pawns[1], pawns[0]}
+	}
+	return t, nil
+}
+
+// Returns the subtable for a side to move (0 for white) and a file of the leading pawn.
+func (t *table) get(stm int, file int) *pairsData {
+	if t.dtz {
+		stm = 0
+	}
+	if !t.hasPawns {
+		file = 0
+	}
+	return &t.items[stm][file]
+}
+
+// Reads and parses the table file in dir, if it hasn't been already.
+func (t *table) load(dir string) error {
+	if t.loaded {
+		return t.err
+	}
+	t.loaded = true
+	ext := ".rtbw"
+	if t.dtz {
+		ext = ".rtbz"
+	}
+	data, err := os.ReadFile(filepath.Join(dir, t.name+ext))
+	if err == nil {
+		err = t.parse(data)
+	}
+	if err != nil {
+		t.err = fmt.Errorf("syzygy: %s%s: %v", t.name, ext, err)
+	}
+	return t.err
+}
+
+// Parses the header of a table file. Offsets are kept relative to the start
+// of the file, since the data is aligned relative to it.
+func (t *table) parse(data []byte) (err error) {
+	magic := wdlMagic
+	if t.dtz {
+		magic = dtzMagic
+	}
+	if len(data) < 5 || [4]byte(data[:4]) != magic {
+		return fmt.Errorf("not a Syzygy table")
+	}
+	const splitFlag, hasPawnsFlag = 1, 2
+	if (data[4]&hasPawnsFlag != 0) != t.hasPawns || (data[4]&splitFlag != 0) == t.symmetric {
+		return fmt.Errorf("header does not match the table name")
+	}
+	// A corrupt header can send offsets out of range
+	defer func() {
+		if recover() != nil {
+			err = fmt.Errorf("corrupt table")
+		}
+	}()
+
+	sides := 1
+	if !t.dtz && !t.symmetric {
+		sides = 2
+	}
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+	bothPawns := t.hasPawns && t.pawnCount[1] > 0
+	p := 5
+	for f := 0; f <= maxFile; f++ {
+		order := [2][2]int{{int(data[p] & 0xF), 0xF}, {int(data[p] >> 4), 0xF}}
+		p++
+		if bothPawns {
+			order[0][1], order[1][1] = int(data[p]&0xF), int(data[p]>>4)
+			p++
+		}
+		for k := 0; k < t.pieceCount; k++ {
+			t.items[0][f].pieces[k] = int(data[p] & 0xF)
+			t.items[1][f].pieces[k] = int(data[p] >> 4)
+			p++
+		}
+		for i := 0; i < sides; i++ {
+			t.setGroups(&t.items[i][f], order[i], f)
+		}
+	}
+	p += p & 1
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			p = t.items[i][f].setSizes(data, p)
+		}
+	}
+	if t.dtz {
+		p = t.setDTZMap(data, p, maxFile)
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			t.items[i][f].sparseIndex = p
+			p += t.items[i][f].sparseIndexSize * 6
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			t.items[i][f].blockLength = p
+			p += t.items[i][f].blockLengthSize * 2
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			p = (p + 0x3F) &^ 0x3F // 64-byte alignment
+			t.items[i][f].data = p
+			p += t.items[i][f].numBlocks * t.items[i][f].blockSize
+		}
+	}
+	if p > len(data) {
+		return fmt.Errorf("table is truncated")
+	}
+	// Decompression reads ahead by up to 8 bytes past the end of a block
+	t.data = append(data, make([]byte, 8)...)
+	return nil
+}
+
+// Groups the pieces that are encoded together, and computes the multiplier of
+// each group's index. Pieces of the same type and color form a group, except
+// that the first group is made of the leading pawns, or (without pawns) of
+// three unique pieces or the two kings. The order argument gives the position
+// of the leading group, and of the other side's pawns, in the encoding.
+func (t *table) setGroups(d *pairsData, order [2]int, file int) {
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+	n := 0
+	d.groupLen[n] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	bothPawns := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - d.groupLen[0]
+	if bothPawns {
+		next = 2
+		freeSquares -= d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		if k == order[0] { // the leading group
+			d.groupIdx[0] = idx
+			if t.hasPawns {
+				idx *= leadPawnsSize[d.groupLen[0]][file]
+			} else if t.hasUniquePieces {
+				idx *= uniquePiecesSize
+			} else {
+				idx *= kingPairSize
+			}
+		} else if k == order[1] { // the other side's pawns
+			d.groupIdx[1] = idx
+			idx *= binomial[d.groupLen[1]][48-d.groupLen[0]]
+		} else {
+			d.groupIdx[next] = idx
+			idx *= binomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// Parses the Huffman code of a subtable, starting at offset p, and returns the
+// offset after it.
+func (d *pairsData) setSizes(data []byte, p int) int {
+	d.flags = data[p]
+	p++
+	if d.flags&flagSingleValue != 0 {
+		d.minSymLen = int(data[p])
+		return p + 1
+	}
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	tbSize := d.groupIdx[n]
+	d.blockSize = 1 << data[p]
+	d.span = 1 << data[p+1]
+	d.sparseIndexSize = int((tbSize + d.span - 1) / d.span)
+	padding := int(data[p+2])
+	d.numBlocks = int(binary.LittleEndian.Uint32(data[p+3:]))
+	d.blockLengthSize = d.numBlocks + padding
+	d.maxSymLen = int(data[p+7])
+	d.minSymLen = int(data[p+8])
+	p += 9
+	d.lowestSym = p
+
+	// In the canonical Huffman code, longer codes have lower values. The
+	// number of codes of each length is the difference of the lowest symbols.
+	lengths := d.maxSymLen - d.minSymLen + 1
+	d.base64 = make([]uint64, lengths)
+	for i := lengths - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowestSymbol(data, i)) - uint64(d.lowestSymbol(data, i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= uint(64 - i - d.minSymLen)
+	}
+	p += lengths * 2
+
+	symbols := int(binary.LittleEndian.Uint16(data[p:]))
+	p += 2
+	d.btree = p
+	d.symlen = make([]int, symbols)
+	visited := make([]bool, symbols)
+	for sym := 0; sym < symbols; sym++ {
+		if !visited[sym] {
+			d.symlen[sym] = d.setSymlen(data, sym, visited)
+		}
+	}
+	return p + symbols*3 + symbols&1
+}
+
+// Computes the number of values, minus one, that a symbol expands to. Each
+// symbol is either a value, or a pair of symbols (recursive pairing).
+func (d *pairsData) setSymlen(data []byte, sym int, visited []bool) int {
+	visited[sym] = true
+	right := d.right(data, sym)
+	if right == 0xFFF {
+		return 0
+	}
+	left := d.left(data, sym)
+	if !visited[left] {
+		d.symlen[left] = d.setSymlen(data, left, visited)
+	}
+	if !visited[right] {
+		d.symlen[right] = d.setSymlen(data, right, visited)
+	}
+	return d.symlen[left] + d.symlen[right] + 1
+}
+
+// Parses the maps from stored DTZ values to actual values, for each result.
+func (t *table) setDTZMap(data []byte, p int, maxFile int) int {
+	for f := 0; f <= maxFile; f++ {
+		d := t.get(0, f)
+		if d.flags&flagMapped == 0 {
+			continue
+		}
+		for i := 0; i < 4; i++ {
+			if d.flags&flagWide != 0 {
+				p += p & 1
+				d.mapIdx[i] = p + 2
+				p += 2 + 2*int(binary.LittleEndian.Uint16(data[p:]))
+			} else {
+				d.mapIdx[i] = p + 1
+				p += 1 + int(data[p])
+			}
+		}
+	}
+	return p + p&1
+}
+
+func (d *pairsData) lowestSymbol(data []byte, length int) int {
+	return int(binary.LittleEndian.Uint16(data[d.lowestSym+2*length:]))
+}
+
+// The left symbol of a pair, or the value of a symbol that is not a pair
+func (d *pairsData) left(data []byte, sym int) int {
+	lr := data[d.btree+3*sym:]
+	return int(lr[1]&0xF)<<8 | int(lr[0])
+}
+
+// The right symbol of a pair, or 0xFFF for a symbol that is not a pair
+func (d *pairsData) right(data []byte, sym int) int {
+	lr := data[d.btree+3*sym:]
+	return int(lr[2])<<4 | int(lr[1]>>4)
+}
+
+func (d *pairsData) blockLen(data []byte, block int) int {
+	return int(binary.LittleEndian.Uint16(data[d.blockLength+2*block:]))
+}
+
+// Returns the value stored at an index of the subtable.
+func (d *pairsData) decompress(data []byte, idx uint64) int {
+	if d.flags&flagSingleValue != 0 {
+		return d.minSymLen
+	}
+
+	// The sparse index gives the block and offset of the value at
+	// k*span + span/2, from which we walk to the block that holds idx
+	k := int(idx / d.span)
+	entry := data[d.sparseIndex+6*k:]
+	block := int(binary.LittleEndian.Uint32(entry))
+	offset := int(binary.LittleEndian.Uint16(entry[4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+	for offset < 0 {
+		block--
+		offset += d.blockLen(data, block) + 1
+	}
+	for offset > d.blockLen(data, block) {
+		offset -= d.blockLen(data, block) + 1
+		block++
+	}
+
+	// Read symbols from the block until reaching the one that contains the offset
+	ptr := d.data + block*d.blockSize
+	buf64 := binary.BigEndian.Uint64(data[ptr:])
+	ptr += 8
+	buf64Size := 64
+	var sym int
+	for {
+		length := 0
+		for buf64 < d.base64[length] {
+			length++
+		}
+		sym = int((buf64-d.base64[length])>>uint(64-length-d.minSymLen)) + d.lowestSymbol(data, length)
+		if offset < d.symlen[sym]+1 {
+			break
+		}
+		offset -= d.symlen[sym] + 1
+		length += d.minSymLen
+		buf64 <<= uint(length)
+		buf64Size -= length
+		if buf64Size <= 32 {
+			buf64Size += 32
+			buf64 |= uint64(binary.BigEndian.Uint32(data[ptr:])) << uint(64-buf64Size)
+			ptr += 4
+		}
+	}
+
+	// Expand the pairs of symbols until reaching the value at the offset
+	for d.symlen[sym] != 0 {
+		left := d.left(data, sym)
+		if offset < d.symlen[left]+1 {
+			sym = left
+		} else {
+			offset -= d.symlen[left] + 1
+			sym = d.right(data, sym)
+		}
+	}
+	return d.left(data, sym)
+}
@@ -0,0 +1,296 @@
+package syzygy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+func TestIndexTables(t *testing.T) {
+	maxCode := 0
+	for idx := range mapKK {
+		for _, code := range mapKK[idx] {
+			if code > maxCode {
+				maxCode = code
+			}
+		}
+	}
+	if maxCode+1 != kingPairSize {
+		t.Error("There are", maxCode+1, "king pairs; expected", kingPairSize)
+	}
+	expected := map[string]int{
+		"a2": 47, "h2": 46, "b2": 35, "a7": 37, "d7": 1, "e7": 0,
+	}
+	for sq, value := range expected {
+		idx, _ := dragontoothmg.AlgebraicToIndex(sq)
+		if mapPawns[idx] != value {
+			t.Error("mapPawns of", sq, "is", mapPawns[idx], "; expected", value)
+		}
+	}
+	if binomial[2][5] != 10 || binomial[5][63] != 7028847 {
+		t.Error("Wrong binomial coefficients:", binomial[2][5], binomial[5][63])
+	}
+	// With one leading pawn, there are six ranks it can be on for each file
+	for f := 0; f < 4; f++ {
+		if leadPawnsSize[1][f] != 6 {
+			t.Error("leadPawnsSize[1] of file", f, "is", leadPawnsSize[1][f])
+		}
+	}
+}
+
+// Creates a table with the given piece order, and encoding order of the groups.
+func testTable(t *testing.T, name string, pieces []int) *table {
+	tbl, err := newTable(name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for f := 0; f < 4; f++ {
+		for stm := 0; stm < 2; stm++ {
+			copy(tbl.items[stm][f].pieces[:], pieces)
+			tbl.setGroups(&tbl.items[stm][f], [2]int{0, 0xF}, f)
+		}
+	}
+	return tbl
+}
+
+// Returns a board with the given pieces, by square.
+func testBoard(pieces map[int]int, wtomove bool) *dragontoothmg.Board {
+	b := dragontoothmg.Board{Wtomove: wtomove}
+	for sq, pc := range pieces {
+		bb := &b.White
+		if pc >= 8 {
+			bb = &b.Black
+		}
+		bit := uint64(1) << uint(sq)
+		switch pc & 7 {
+		case dragontoothmg.Pawn:
+			bb.Pawns |= bit
+		case dragontoothmg.Knight:
+			bb.Knights |= bit
+		case dragontoothmg.Bishop:
+			bb.Bishops |= bit
+		case dragontoothmg.Rook:
+			bb.Rooks |= bit
+		case dragontoothmg.Queen:
+			bb.Queens |= bit
+		case dragontoothmg.King:
+			bb.Kings |= bit
+		}
+		bb.All |= bit
+	}
+	return &b
+}
+
+// Checks that positions are encoded in range, that positions that are mirror
+// images of each other share an index, and that other positions do not.
+func checkEncoding(t *testing.T, tbl *table, squares func(func([3]int)), symmetries [][3]int, codes [3]int) {
+	type key struct {
+		file int
+		idx  uint64
+	}
+	seen := make(map[key][3]int)
+	squares(func(sq [3]int) {
+		// The canonical form of a position is its smallest mirror image
+		canonical := sq
+		for _, sym := range symmetries {
+			var image [3]int
+			for i := range sq {
+				image[i] = transform(sq[i], sym)
+			}
+			if image[0] < canonical[0] || (image[0] == canonical[0] &&
+				(image[1] < canonical[1] || (image[1] == canonical[1] && image[2] < canonical[2]))) {
+				canonical = image
+			}
+		}
+		b := testBoard(map[int]int{sq[0]: codes[0], sq[1]: codes[1], sq[2]: codes[2]}, true)
+		d, file, idx, _ := tbl.encode(b, false)
+		n := 0
+		for d.groupLen[n] != 0 {
+			n++
+		}
+		if idx >= d.groupIdx[n] {
+			t.Fatal("Index", idx, "of", sq, "is out of range")
+		}
+		if other, ok := seen[key{file, idx}]; ok && other != canonical {
+			t.Fatal("Positions", other, "and", canonical, "have the same index")
+		}
+		seen[key{file, idx}] = canonical
+	})
+}
+
+// Applies a symmetry, given as (mirror files, mirror ranks, transpose), to a square.
+func transform(sq int, sym [3]int) int {
+	if sym[0] != 0 {
+		sq ^= 7
+	}
+	if sym[1] != 0 {
+		sq ^= 56
+	}
+	if sym[2] != 0 {
+		sq = (sq>>3 | sq<<3) & 63
+	}
+	return sq
+}
+
+func kingsAdjacent(a, b int) bool {
+	return abs(a>>3-b>>3) <= 1 && abs(a&7-b&7) <= 1
+}
+
+func TestEncodePawnless(t *testing.T) {
+	const wK, wQ, bK = dragontoothmg.King, dragontoothmg.Queen, dragontoothmg.King + 8
+	var symmetries [][3]int
+	for i := 0; i < 8; i++ {
+		symmetries = append(symmetries, [3]int{i & 1, i >> 1 & 1, i >> 2})
+	}
+	// Three unique pieces
+	tbl := testTable(t, "KQvK", []int{wK, wQ, bK})
+	checkEncoding(t, tbl, func(visit func([3]int)) {
+		for k := 0; k < 64; k++ {
+			for q := 0; q < 64; q++ {
+				for k2 := 0; k2 < 64; k2++ {
+					if q != k && q != k2 && !kingsAdjacent(k, k2) {
+						visit([3]int{k, q, k2})
+					}
+				}
+			}
+		}
+	}, symmetries, [3]int{wK, wQ, bK})
+
+	// The kings, and a group of two queens
+	tbl = testTable(t, "KQQvK", []int{wK, bK, wQ, wQ})
+	if tbl.items[0][0].groupLen != [8]int{2, 2, 0} {
+		t.Fatal("Wrong groups for KQQvK:", tbl.items[0][0].groupLen)
+	}
+	for k := 0; k < 64; k++ {
+		for k2 := 0; k2 < 64; k2++ {
+			if kingsAdjacent(k, k2) || k == 27 || k == 36 || k2 == 27 || k2 == 36 {
+				continue
+			}
+			b := testBoard(map[int]int{k: wK, k2: bK, 27: wQ, 36: wQ}, true)
+			if _, _, idx, _ := tbl.encode(b, false); idx >= tbl.items[0][0].groupIdx[2] {
+				t.Fatal("Index", idx, "is out of range")
+			}
+		}
+	}
+}
+
+func TestEncodePawns(t *testing.T) {
+	const wP, wK, bK = dragontoothmg.Pawn, dragontoothmg.King, dragontoothmg.King + 8
+	tbl := testTable(t, "KPvK", []int{wP, wK, bK})
+	checkEncoding(t, tbl, func(visit func([3]int)) {
+		for p := 8; p < 56; p++ {
+			for k := 0; k < 64; k++ {
+				for k2 := 0; k2 < 64; k2++ {
+					if p != k && p != k2 && !kingsAdjacent(k, k2) {
+						visit([3]int{p, k, k2})
+					}
+				}
+			}
+		}
+	}, [][3]int{{0, 0, 0}, {1, 0, 0}}, [3]int{wP, wK, bK})
+
+	// The leading pawn is the one nearest the edge, and then the lowest
+	b := testBoard(map[int]int{12: wP, 49: wP, 4: wK, 60: bK}, true)
+	tbl = testTable(t, "KPPvK", []int{wP, wP, wK, bK})
+	if _, file, _, _ := tbl.encode(b, false); file != 1 {
+		t.Error("Leading pawn is on file", file, "; expected 1")
+	}
+}
+
+// A symbol of the test Huffman code
+type testSymbol struct {
+	code   string
+	values []int
+}
+
+var (
+	symbol10   = testSymbol{"00", []int{10}}
+	symbol20   = testSymbol{"01", []int{20}}
+	symbolPair = testSymbol{"1", []int{10, 20}}
+)
+
+// Builds a subtable from blocks of symbols, parses it, and checks that every
+// value decompresses correctly.
+func TestDecompress(t *testing.T) {
+	blocks := [][]testSymbol{
+		{symbol10, symbolPair, symbol20, symbol20},
+		{symbolPair, symbolPair, symbolPair},
+		{symbol20},
+		{symbol10, symbol10, symbolPair, symbol20, symbolPair, symbol10, symbol20, symbolPair},
+		{symbol20, symbol10},
+	}
+	const blockSize, span = 16, 4
+	var values []int
+	var blockLength []int
+	var blockData []byte
+	for _, block := range blocks {
+		bits := ""
+		n := 0
+		for _, sym := range block {
+			bits += sym.code
+			values = append(values, sym.values...)
+			n += len(sym.values)
+		}
+		blockLength = append(blockLength, n-1)
+		packed := make([]byte, blockSize)
+		for i, c := range bits {
+			if c == '1' {
+				packed[i/8] |= 0x80 >> uint(i%8)
+			}
+		}
+		blockData = append(blockData, packed...)
+	}
+
+	var buf bytes.Buffer
+	le := func(v interface{}) { binary.Write(&buf, binary.LittleEndian, v) }
+	buf.Write([]byte{0, 4, 2, 0}) // flags, block size and span (log2), padding
+	le(uint32(len(blocks)))
+	buf.Write([]byte{2, 1}) // maximum and minimum code length
+	le([]uint16{2, 0})      // lowest symbol of each length
+	le(uint16(3))           // number of symbols
+	// The symbols 10, 20, and the pair of both, then padding
+	buf.Write([]byte{10, 0xF0, 0xFF, 20, 0xF0, 0xFF, 0, 0x10, 0, 0})
+	header := buf.Len()
+
+	// Each sparse index entry locates the value in the middle of its span
+	for k := 0; k*span < len(values); k++ {
+		v := k*span + span/2
+		block := 0
+		for block < len(blockLength)-1 && v > blockLength[block] {
+			v -= blockLength[block] + 1
+			block++
+		}
+		le(uint32(block))
+		le(uint16(v))
+	}
+	for _, n := range blockLength {
+		le(uint16(n))
+	}
+	for buf.Len()%64 != 0 {
+		buf.WriteByte(0)
+	}
+	dataOffset := buf.Len()
+	buf.Write(blockData)
+	buf.Write(make([]byte, 8))
+	data := buf.Bytes()
+
+	var d pairsData
+	d.groupLen[0] = 1
+	d.groupIdx[1] = uint64(len(values))
+	if p := d.setSizes(data, 0); p != header {
+		t.Fatal("Header ends at", p, "; expected", header)
+	}
+	d.sparseIndex = header
+	d.blockLength = header + d.sparseIndexSize*6
+	d.data = dataOffset
+	if d.symlen[2] != 1 {
+		t.Error("A pair of values has symlen", d.symlen[2])
+	}
+	for i, v := range values {
+		if got := d.decompress(data, uint64(i)); got != v {
+			t.Error("Value", i, "is", got, "; expected", v)
+		}
+	}
+}
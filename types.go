@@ -16,8 +16,10 @@ package dragontoothmg
 // The board type, which uses little-endian rank-file mapping.
 type Board struct {
 	Wtomove       bool
+	Chess960      bool  // if set, castling moves are encoded as the king capturing its own rook
 	enpassant     uint8 // square id (16-23 or 40-47) where en passant capture is possible
 	castlerights  uint8
+	castlerooks   [4]uint8 // the rook square for each castling right, in castlerights bit order
 	Halfmoveclock uint8
 	Fullmoveno    uint16
 	White         Bitboards
@@ -34,13 +36,19 @@ func (b *Board) Hash() uint64 {
 	return b.hash
 }
 
+// Determines whether either side still has any castling rights.
+func (b *Board) HasCastlingRights() bool {
+	return b.castlerights != 0
+}
+
 // Castle rights helpers. Data stored inside, from LSB:
 // 1 bit: White castle queenside
 // 1 bit: White castle kingside
 // 1 bit: Black castle queenside
 // 1 bit: Black castle kingside
 // This just indicates whether castling rights have been lost, not whether
-// castling is actually possible.
+// castling is actually possible. The square of the rook that castles for
+// each right is stored in castlerooks, since in Chess960 it can be on any file.
 
 // Castling helper functions for all 16 possible scenarios
 func (b *Board) whiteCanCastleQueenside() bool {
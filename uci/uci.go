@@ -0,0 +1,316 @@
+// Package uci implements the engine side of the Universal Chess Interface (UCI)
+// protocol, so that an engine built on dragontoothmg can be driven by any
+// UCI-compatible GUI or testing harness.
+//
+// The protocol handling, position tracking and perft are provided here; the
+// engine supplies the search as a callback.
+package uci
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// The search constraints sent with the "go" command. Zero values mean the
+// constraint was not given.
+type Limits struct {
+	WTime, BTime time.Duration // Time remaining on each clock
+	WInc, BInc   time.Duration // Increment per move for each side
+	MovesToGo    int           // Moves until the next time control
+	Depth        int           // Maximum search depth, in plies
+	Nodes        int64         // Maximum number of nodes to search
+	Mate         int           // Search for a mate in this many moves
+	MoveTime     time.Duration // Exact time to search
+	Infinite     bool          // Search until the "stop" command
+	Ponder       bool          // Search in the opponent's time (see PonderHit)
+	SearchMoves  []dragontoothmg.Move
+
+	// When pondering, closed by the "ponderhit" command: the opponent played
+	// the expected move, and the search now runs under the other limits.
+	PonderHit <-chan struct{}
+}
+
+// A SearchFunc searches a position and returns the best move.
+// It must return promptly once the stop channel is closed. It may call info to
+// report progress to the GUI, with the text of an "info" line (e.g. "depth 3 score cp 20").
+type SearchFunc func(b dragontoothmg.Board, limits Limits, stop <-chan struct{}, info func(string)) dragontoothmg.Move
+
+// An Engine is a UCI front end for a search function.
+type Engine struct {
+	Name   string
+	Author string
+	Search SearchFunc
+
+	board     dragontoothmg.Board
+	out       io.Writer
+	outMu     sync.Mutex
+	stop      chan struct{}
+	ponderhit chan struct{}
+	searching sync.WaitGroup
+}
+
+// Reads UCI commands from in and writes responses to out, until the "quit"
+// command or the end of the input.
+func (e *Engine) Run(in io.Reader, out io.Writer) error {
+	e.out = out
+	e.board = dragontoothmg.ParseFen(dragontoothmg.Startpos)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			e.send("id name %v", e.Name)
+			e.send("id author %v", e.Author)
+			e.send("uciok")
+		case "isready":
+			e.send("readyok")
+		case "ucinewgame":
+			e.stopSearch()
+			e.board = dragontoothmg.ParseFen(dragontoothmg.Startpos)
+		case "position":
+			e.stopSearch()
+			e.position(fields[1:])
+		case "go":
+			e.stopSearch()
+			e.goCommand(fields[1:])
+		case "stop":
+			e.stopSearch()
+		case "quit":
+			e.stopSearch()
+			return nil
+		case "ponderhit":
+			e.ponderHit()
+		case "debug", "setoption", "register":
+			// Not supported; ignored as the protocol requires.
+		default:
+			e.send("info string unknown command: %v", fields[0])
+		}
+	}
+	e.stopSearch()
+	return scanner.Err()
+}
+
+// Writes a line of output.
+func (e *Engine) send(format string, args ...interface{}) {
+	e.outMu.Lock()
+	defer e.outMu.Unlock()
+	fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+// Handles "position [startpos | fen <fen>] [moves <move>...]".
+func (e *Engine) position(args []string) {
+	if len(args) == 0 {
+		e.send("info string missing position")
+		return
+	}
+	movesIdx := len(args)
+	for i, arg := range args {
+		if arg == "moves" {
+			movesIdx = i
+			break
+		}
+	}
+	var b dragontoothmg.Board
+	switch args[0] {
+	case "startpos":
+		b = dragontoothmg.ParseFen(dragontoothmg.Startpos)
+	case "fen":
+		var err error
+		b, err = dragontoothmg.ParseFenStrict(strings.Join(args[1:movesIdx], " "))
+		if err != nil {
+			e.send("info string %v", err)
+			return
+		}
+	default:
+		e.send("info string invalid position: %v", args[0])
+		return
+	}
+	if movesIdx < len(args) {
+		for _, movestr := range args[movesIdx+1:] {
+			mv, err := dragontoothmg.ParseMove(movestr)
+			if err != nil || !isLegal(&b, mv) {
+				e.send("info string illegal move: %v", movestr)
+				break
+			}
+			b.Apply(mv)
+		}
+	}
+	e.board = b
+}
+
+// Handles "go", either starting a search or running perft.
+func (e *Engine) goCommand(args []string) {
+	var limits Limits
+	for i := 0; i < len(args); i++ {
+		// Most limits take one numeric argument
+		var value int64
+		if i+1 < len(args) {
+			value, _ = strconv.ParseInt(args[i+1], 10, 64)
+		}
+		ms := time.Duration(value) * time.Millisecond
+		switch args[i] {
+		case "perft":
+			depth, board := int(value), e.board
+			e.start(func(stop <-chan struct{}) { e.perft(board, depth, stop) })
+			return
+		case "wtime":
+			limits.WTime = ms
+		case "btime":
+			limits.BTime = ms
+		case "winc":
+			limits.WInc = ms
+		case "binc":
+			limits.BInc = ms
+		case "movestogo":
+			limits.MovesToGo = int(value)
+		case "depth":
+			limits.Depth = int(value)
+		case "nodes":
+			limits.Nodes = value
+		case "mate":
+			limits.Mate = int(value)
+		case "movetime":
+			limits.MoveTime = ms
+		case "infinite":
+			limits.Infinite = true
+			continue
+		case "ponder":
+			limits.Ponder = true
+			continue
+		case "searchmoves":
+			for i+1 < len(args) {
+				mv, err := dragontoothmg.ParseMove(args[i+1])
+				if err != nil || !isLegal(&e.board, mv) {
+					break
+				}
+				limits.SearchMoves = append(limits.SearchMoves, mv)
+				i++
+			}
+			continue
+		default:
+			continue
+		}
+		i++ // skip the argument
+	}
+
+	var ponderhit chan struct{}
+	if limits.Ponder {
+		ponderhit = make(chan struct{})
+		limits.PonderHit = ponderhit
+	}
+	board := e.board
+	e.start(func(stop <-chan struct{}) {
+		var best dragontoothmg.Move
+		if len(board.GenerateLegalMoves()) != 0 && e.Search != nil {
+			best = e.Search(board, limits, stop, func(s string) { e.send("info %v", s) })
+		}
+		// bestmove must not be sent before "stop" in infinite mode,
+		// or before "stop" or "ponderhit" while pondering
+		if limits.Infinite {
+			<-stop
+		} else if limits.Ponder {
+			select {
+			case <-stop:
+			case <-ponderhit:
+			}
+		}
+		e.send("bestmove %v", &best)
+	})
+	e.ponderhit = ponderhit
+}
+
+// Runs a search (or perft) on its own goroutine, so that "stop" and "quit"
+// can interrupt it through the stop channel.
+func (e *Engine) start(search func(stop <-chan struct{})) {
+	stop := make(chan struct{})
+	e.stop = stop
+	e.searching.Add(1)
+	go func() {
+		defer e.searching.Done()
+		search(stop)
+	}()
+}
+
+// Stops the current search, if any, and waits for it to report its move.
+func (e *Engine) stopSearch() {
+	if e.stop != nil {
+		close(e.stop)
+		e.stop = nil
+	}
+	e.ponderhit = nil
+	e.searching.Wait()
+}
+
+// Handles "ponderhit", letting a pondering search continue as a normal one.
+func (e *Engine) ponderHit() {
+	if e.ponderhit != nil {
+		close(e.ponderhit)
+		e.ponderhit = nil
+	}
+}
+
+// Handles "go perft <depth>", printing the node count for each move
+// in the same format as other UCI engines. If stopped, the moves counted so
+// far are reported without a total.
+func (e *Engine) perft(b dragontoothmg.Board, depth int, stop <-chan struct{}) {
+	if depth < 1 {
+		depth = 1
+	}
+	var total int64
+	for _, mv := range b.GenerateLegalMoves() {
+		unapply := b.Apply(mv)
+		count, ok := perft(&b, depth-1, stop)
+		unapply()
+		if !ok {
+			e.send("info string perft stopped")
+			return
+		}
+		total += count
+		e.send("%v: %v", &mv, count)
+	}
+	e.send("\nNodes searched: %v\n", total)
+}
+
+// Like dragontoothmg.Perft, but gives up (returning false) once stop is closed.
+// The channel is checked away from the leaves, where it would cost the most.
+func perft(b *dragontoothmg.Board, n int, stop <-chan struct{}) (int64, bool) {
+	if n <= 2 {
+		return dragontoothmg.Perft(b, n), true
+	}
+	select {
+	case <-stop:
+		return 0, false
+	default:
+	}
+	var count int64
+	for _, mv := range b.GenerateLegalMoves() {
+		unapply := b.Apply(mv)
+		sub, ok := perft(b, n-1, stop)
+		unapply()
+		if !ok {
+			return 0, false
+		}
+		count += sub
+	}
+	return count, true
+}
+
+// Whether a move is legal in the given position.
+func isLegal(b *dragontoothmg.Board, mv dragontoothmg.Move) bool {
+	for _, legal := range b.GenerateLegalMoves() {
+		if legal == mv {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package uci
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dylhunn/dragontoothmg"
+)
+
+// Runs an engine on a script of commands, and returns its output lines.
+func runScript(e *Engine, script string) []string {
+	var out bytes.Buffer
+	e.Run(strings.NewReader(script), &out)
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHandshake(t *testing.T) {
+	e := &Engine{Name: "Test", Author: "Tester"}
+	lines := runScript(e, "uci\nisready\nquit\n")
+	expected := []string{"id name Test", "id author Tester", "uciok", "readyok"}
+	if len(lines) != len(expected) {
+		t.Fatal("Wrong handshake:", lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Error("Wrong handshake line", i, ":", lines[i], "instead of", expected[i])
+		}
+	}
+}
+
+func TestPositionAndSearch(t *testing.T) {
+	var searched dragontoothmg.Board
+	var searchedLimits Limits
+	e := &Engine{Search: func(b dragontoothmg.Board, limits Limits, stop <-chan struct{}, info func(string)) dragontoothmg.Move {
+		searched, searchedLimits = b, limits
+		info("depth 1")
+		return b.GenerateLegalMoves()[0]
+	}}
+	lines := runScript(e, "position startpos moves e2e4 e7e5 g1f3\ngo wtime 1000 btime 2000 winc 10 movestogo 5 depth 3\nisready\nquit\n")
+	if fen := searched.ToFen(); fen != "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2" {
+		t.Error("Searched the wrong position:", fen)
+	}
+	if searchedLimits.WTime.Seconds() != 1 || searchedLimits.BTime.Seconds() != 2 ||
+		searchedLimits.WInc.Nanoseconds() != 10000000 || searchedLimits.MovesToGo != 5 || searchedLimits.Depth != 3 {
+		t.Error("Wrong search limits:", searchedLimits)
+	}
+	if !contains(lines, "info depth 1") || !contains(lines, "bestmove "+searched.GenerateLegalMoves()[0].String()) {
+		t.Error("Wrong search output:", lines)
+	}
+
+	lines = runScript(e, "position fen 4k3/8/8/8/8/8/4P3/4K3 b - - 0 40 moves e8d7\ngo searchmoves e2e4 e2e3 infinite\nstop\n")
+	if fen := searched.ToFen(); fen != "8/3k4/8/8/8/8/4P3/4K3 w - - 1 41" {
+		t.Error("Searched the wrong position:", fen)
+	}
+	if !searchedLimits.Infinite || len(searchedLimits.SearchMoves) != 2 {
+		t.Error("Wrong search limits:", searchedLimits)
+	}
+	if !contains(lines, "bestmove "+searched.GenerateLegalMoves()[0].String()) {
+		t.Error("Wrong search output:", lines)
+	}
+}
+
+func TestInvalidPositions(t *testing.T) {
+	e := &Engine{}
+	lines := runScript(e, "position fen 8/8/8/8/8/8/8/8 w - - 0 1\nposition startpos moves e2e5\nposition bogus\n")
+	if len(lines) != 3 {
+		t.Fatal("Expected an info line for each invalid position, but got", lines)
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "info string") {
+			t.Error("Expected an info line, but got", line)
+		}
+	}
+	// Moves up to the illegal one are still applied.
+	if fen := e.board.ToFen(); fen != dragontoothmg.Startpos {
+		t.Error("Wrong position after invalid commands:", fen)
+	}
+	runScript(e, "position startpos moves e2e4 e7e4 d2d4\n")
+	if fen := e.board.ToFen(); fen != "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1" {
+		t.Error("Wrong position after an illegal move:", fen)
+	}
+}
+
+func TestGoPerft(t *testing.T) {
+	e := &Engine{}
+	lines := runScript(e, "position fen r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1\ngo perft 2\nquit\n")
+	if lines[len(lines)-1] != "Nodes searched: 2039" {
+		t.Error("Wrong perft total:", lines[len(lines)-1])
+	}
+	if !contains(lines, "e1g1: 43") || len(lines) != 50 {
+		t.Error("Wrong perft division:", lines)
+	}
+}
+
+func TestStopPerft(t *testing.T) {
+	e := &Engine{}
+	done := make(chan []string)
+	go func() { done <- runScript(e, "go perft 9\nstop\nquit\n") }()
+	select {
+	case lines := <-done:
+		if lines[len(lines)-1] != "info string perft stopped" {
+			t.Error("Perft was not stopped:", lines)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Perft ignored the stop command")
+	}
+}
+
+func TestPonder(t *testing.T) {
+	var searchedLimits Limits
+	e := &Engine{Search: func(b dragontoothmg.Board, limits Limits, stop <-chan struct{}, info func(string)) dragontoothmg.Move {
+		searchedLimits = limits
+		select {
+		case <-limits.PonderHit:
+		case <-stop:
+		}
+		// "quit" may stop the search right after "ponderhit" closed PonderHit
+		select {
+		case <-limits.PonderHit:
+			info("string ponderhit")
+		default:
+		}
+		return b.GenerateLegalMoves()[0]
+	}}
+	lines := runScript(e, "go ponder wtime 1000\nponderhit\nquit\n")
+	if !searchedLimits.Ponder || searchedLimits.WTime.Seconds() != 1 {
+		t.Error("Wrong search limits:", searchedLimits)
+	}
+	if len(lines) != 2 || lines[0] != "info string ponderhit" || !strings.HasPrefix(lines[1], "bestmove ") {
+		t.Error("Wrong ponder output:", lines)
+	}
+}
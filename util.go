@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/bits"
 	"strconv"
 	"strings"
 )
@@ -41,13 +42,14 @@ func recomputeBoardHash(b *Board) uint64 {
 
 func IsCapture(m Move, b *Board) bool {
 	toBitboard := (uint64(1) << m.To())
-	if (toBitboard&b.White.All != 0) || (toBitboard&b.Black.All != 0) {
+	// Only opponent pieces can be captured (in Chess960, castling moves onto our own rook)
+	if (b.Wtomove && toBitboard&b.Black.All != 0) || (!b.Wtomove && toBitboard&b.White.All != 0) {
 		return true
 	}
 	// Is it an en passant capture?
 	fromBitboard := (uint64(1) << m.From())
 	originIsPawn := fromBitboard&b.White.Pawns != 0 || fromBitboard&b.Black.Pawns != 0
-	return originIsPawn && (toBitboard&(uint64(1) << b.enpassant) != 0)
+	return originIsPawn && b.enpassant != 0 && (toBitboard&(uint64(1)<<b.enpassant) != 0)
 }
 
 // A testing-use function that ignores the error output
@@ -152,7 +154,20 @@ func IndexToAlgebraic(id Square) string {
 }
 
 // Serializes a board position to a Fen string.
+// Chess960 castling rights are written in X-FEN, which uses KQkq unless another
+// rook stands between the king and the castling rook's corner.
 func (b *Board) ToFen() string {
+	return b.toFen(false)
+}
+
+// Serializes a board position to a Fen string, writing Chess960 castling rights
+// in Shredder-FEN, which always gives the file of the castling rook (e.g. HAha).
+// Boards that are not Chess960 are written exactly as by ToFen.
+func (b *Board) ToShredderFen() string {
+	return b.toFen(true)
+}
+
+func (b *Board) toFen(shredder bool) string {
 	b.White.sanityCheck()
 	b.Black.sanityCheck()
 	var position string
@@ -214,28 +229,7 @@ func (b *Board) ToFen() string {
 	} else {
 		position += " b"
 	}
-	position += " "
-	castleCount := 0
-	if b.whiteCanCastleKingside() {
-		position += "K"
-		castleCount++
-	}
-	if b.whiteCanCastleQueenside() {
-		position += "Q"
-		castleCount++
-	}
-	if b.blackCanCastleKingside() {
-		position += "k"
-		castleCount++
-	}
-	if b.blackCanCastleQueenside() {
-		position += "q"
-		castleCount++
-	}
-	if castleCount == 0 {
-		position += "-"
-	}
-	position += " "
+	position += " " + b.castlingFen(shredder) + " "
 	if b.enpassant != 0 {
 		position += IndexToAlgebraic(Square(b.enpassant))
 	} else {
@@ -245,92 +239,329 @@ func (b *Board) ToFen() string {
 	return position
 }
 
+// Returns the castling field of a FEN string.
+func (b *Board) castlingFen(shredder bool) string {
+	var field string
+	for _, right := range [4]uint8{1, 0, 3, 2} { // KQkq order
+		if b.castlerights&(1<<right) == 0 {
+			continue
+		}
+		rook := b.castlerooks[right]
+		ourRooks, backRank := b.White.Rooks&onlyRank[0], onlyRank[0]
+		if right >= 2 {
+			ourRooks, backRank = b.Black.Rooks&onlyRank[7], onlyRank[7]
+		}
+		// The rooks that stand further out on the castling side than the castling rook
+		outside := ourRooks & backRank & ((uint64(1) << rook) - 1)
+		if right%2 == 1 {
+			outside = ourRooks & backRank & ^((uint64(1) << (rook + 1)) - 1)
+		}
+		letter := "QK"[right%2]
+		if b.Chess960 && (shredder || outside != 0) {
+			letter = 'A' + rook%8
+		}
+		if right >= 2 {
+			letter += 'a' - 'A'
+		}
+		field += string(letter)
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
 // Parse a board from a FEN string.
+// This is a convenience wrapper for trusted input. The position itself is not
+// validated, and a FEN with malformed fields produces a blank Board.
+// Use ParseFenStrict to find out what is wrong with a FEN, and ParseChess960Fen
+// for Chess960 positions whose castling field uses KQkq.
 func ParseFen(fen string) Board {
-	// BUG(dylhunn): This FEN parsing implementation doesn't handle malformed inputs.
+	b, err := parseFenFields(strings.Fields(fen), false)
+	if err != nil {
+		return Board{}
+	}
+	return b
+}
+
+// Parse a board from a FEN string, reporting an error if the FEN is malformed
+// or describes an illegal position. In addition to checking the syntax of each
+// field, this verifies that each side has exactly one king, that no pawns are on
+// the first or last rank, that the side not to move is not in check, that the
+// castling rights agree with the king and rook placement, and that the en passant
+// square could have resulted from a double pawn push.
+// A castling field with the files of the castling rooks (Shredder-FEN or
+// X-FEN) makes the board a Chess960 board; KQkq always denote standard castling.
+func ParseFenStrict(fen string) (Board, error) {
+	return parseFenStrict(fen, false)
+}
+
+// Parse a Chess960 board from a FEN string, reporting errors like ParseFenStrict.
+// Unlike ParseFenStrict, the castling field may use KQkq for a king and rooks
+// away from their standard squares, as X-FEN does when the castling rook is the
+// outermost one on its side.
+func ParseChess960Fen(fen string) (Board, error) {
+	return parseFenStrict(fen, true)
+}
+
+func parseFenStrict(fen string, chess960 bool) (Board, error) {
 	tokens := strings.Fields(fen)
-	var b Board
-	// replace digits with the appropriate number of dashes
-	for i := 1; i <= 8; i++ {
-		var replacement string
-		for j := 0; j < i; j++ {
-			replacement += "-"
-		}
-		tokens[0] = strings.Replace(tokens[0], strconv.Itoa(i), replacement, -1)
+	if len(tokens) > 6 {
+		return Board{}, fmt.Errorf("Invalid FEN: expected at most 6 fields, found %d", len(tokens))
 	}
-	// reverse the order of the ranks, removing slashes
-	ranks := strings.Split(tokens[0], "/")
-	for i := 0; i < len(ranks)/2; i++ {
-		j := len(ranks) - i - 1
-		ranks[i], ranks[j] = ranks[j], ranks[i]
+	b, err := parseFenFields(tokens, chess960)
+	if err != nil {
+		return Board{}, err
+	}
+	// The en passant square must be on the rank behind a pawn that was just
+	// pushed. This checks the field itself, since a1 is stored as no square.
+	if ep := tokens[3]; ep != "-" {
+		rank := byte('3')
+		if b.Wtomove {
+			rank = '6'
+		}
+		if ep[1] != rank {
+			return Board{}, fmt.Errorf("Invalid FEN en passant square %v: wrong rank for the side to move", ep)
+		}
 	}
-	tokens[0] = ranks[0]
-	for i := 1; i < len(ranks); i++ {
-		tokens[0] += ranks[i]
+	if err := b.validate(); err != nil {
+		return Board{}, err
 	}
-	// add every piece to the board
-	for i := uint8(0); i < 64; i++ {
-		switch tokens[0][i] {
-		case 'p':
-			b.Black.Pawns |= 1 << i
-		case 'n':
-			b.Black.Knights |= 1 << i
-		case 'b':
-			b.Black.Bishops |= 1 << i
-		case 'r':
-			b.Black.Rooks |= 1 << i
-		case 'q':
-			b.Black.Queens |= 1 << i
-		case 'k':
-			b.Black.Kings |= 1 << i
-		case 'P':
-			b.White.Pawns |= 1 << i
-		case 'N':
-			b.White.Knights |= 1 << i
-		case 'B':
-			b.White.Bishops |= 1 << i
-		case 'R':
-			b.White.Rooks |= 1 << i
-		case 'Q':
-			b.White.Queens |= 1 << i
-		case 'K':
-			b.White.Kings |= 1 << i
+	return b, nil
+}
+
+// Parses the fields of a FEN string, checking only the syntax of each field.
+// The clock fields are optional.
+func parseFenFields(tokens []string, chess960 bool) (Board, error) {
+	b := Board{Chess960: chess960}
+	if len(tokens) < 4 {
+		return b, fmt.Errorf("Invalid FEN: expected at least 4 fields, found %d", len(tokens))
+	}
+	// FEN ranks run from 8 down to 1
+	ranks := strings.Split(tokens[0], "/")
+	if len(ranks) != 8 {
+		return b, fmt.Errorf("Invalid FEN piece placement %q: expected 8 ranks, found %d",
+			tokens[0], len(ranks))
+	}
+	for i, rank := range ranks {
+		rankNo := 8 - i
+		file := 0
+		for _, c := range rank {
+			if c >= '1' && c <= '8' {
+				file += int(c - '0')
+				continue
+			}
+			if file >= 8 {
+				file++
+				break
+			}
+			sq := uint64(1) << uint8((rankNo-1)*8+file)
+			switch c {
+			case 'p':
+				b.Black.Pawns |= sq
+			case 'n':
+				b.Black.Knights |= sq
+			case 'b':
+				b.Black.Bishops |= sq
+			case 'r':
+				b.Black.Rooks |= sq
+			case 'q':
+				b.Black.Queens |= sq
+			case 'k':
+				b.Black.Kings |= sq
+			case 'P':
+				b.White.Pawns |= sq
+			case 'N':
+				b.White.Knights |= sq
+			case 'B':
+				b.White.Bishops |= sq
+			case 'R':
+				b.White.Rooks |= sq
+			case 'Q':
+				b.White.Queens |= sq
+			case 'K':
+				b.White.Kings |= sq
+			default:
+				return b, fmt.Errorf("Invalid FEN piece placement %q: unexpected character %q in rank %d",
+					tokens[0], c, rankNo)
+			}
+			file++
+		}
+		if file != 8 {
+			return b, fmt.Errorf("Invalid FEN piece placement %q: rank %d does not describe 8 squares",
+				tokens[0], rankNo)
 		}
 	}
 	b.White.All = b.White.Pawns | b.White.Knights | b.White.Bishops | b.White.Rooks | b.White.Queens | b.White.Kings
 	b.Black.All = b.Black.Pawns | b.Black.Knights | b.Black.Bishops | b.Black.Rooks | b.Black.Queens | b.Black.Kings
 
-	b.Wtomove = tokens[1] == "w" || tokens[1] == "W"
-	if strings.Contains(tokens[2], "K") {
-		b.flipWhiteKingsideCastle()
-	}
-	if strings.Contains(tokens[2], "Q") {
-		b.flipWhiteQueensideCastle()
+	switch tokens[1] {
+	case "w":
+		b.Wtomove = true
+	case "b":
+		b.Wtomove = false
+	default:
+		return b, fmt.Errorf("Invalid FEN side to move %q", tokens[1])
 	}
-	if strings.Contains(tokens[2], "k") {
-		b.flipBlackKingsideCastle()
-	}
-	if strings.Contains(tokens[2], "q") {
-		b.flipBlackQueensideCastle()
+
+	// Castling rights may be given as KQkq, or as the file of the castling rook
+	// (Shredder-FEN and X-FEN). KQkq denote the outermost rook on that side.
+	b.castlerooks = [4]uint8{0, 7, 56, 63}
+	if tokens[2] != "-" {
+		for _, original := range tokens[2] {
+			c := original
+			ourPieces, backRank, right := &(b.White), uint8(0), uint8(0)
+			if c >= 'a' && c <= 'z' {
+				ourPieces, backRank, right = &(b.Black), 56, 2
+				c -= 'a' - 'A'
+			}
+			kingFile := 4 // if the king is not on the back rank, assume the standard square
+			if kings := ourPieces.Kings & onlyRank[backRank/8]; kings != 0 {
+				kingFile = bits.TrailingZeros64(kings) % 8
+			}
+			rookFile := -1
+			switch {
+			case c == 'K':
+				right++
+				for file := 7; file > kingFile && rookFile == -1; file-- {
+					if ourPieces.Rooks&(uint64(1)<<(backRank+uint8(file))) != 0 {
+						rookFile = file
+					}
+				}
+				if rookFile == -1 {
+					rookFile = 7
+				}
+			case c == 'Q':
+				for file := 0; file < kingFile && rookFile == -1; file++ {
+					if ourPieces.Rooks&(uint64(1)<<(backRank+uint8(file))) != 0 {
+						rookFile = file
+					}
+				}
+				if rookFile == -1 {
+					rookFile = 0
+				}
+			case c >= 'A' && c <= 'H' && int(c-'A') != kingFile:
+				rookFile = int(c - 'A')
+				if rookFile > kingFile {
+					right++
+				}
+				b.Chess960 = true
+			default:
+				return b, fmt.Errorf("Invalid FEN castling rights %q: unexpected character %q", tokens[2], original)
+			}
+			if b.castlerights&(1<<right) != 0 {
+				return b, fmt.Errorf("Invalid FEN castling rights %q: repeated %q", tokens[2], original)
+			}
+			b.castlerights |= 1 << right
+			b.castlerooks[right] = backRank + uint8(rookFile)
+		}
 	}
 	if tokens[3] != "-" {
+		if len(tokens[3]) != 2 {
+			return b, fmt.Errorf("Invalid FEN en passant square %q", tokens[3])
+		}
 		res, err := AlgebraicToIndex(tokens[3])
 		if err != nil {
-			var b2 Board
-			return b2 // TODO(dylhunn): return error instead of blank board
+			return b, fmt.Errorf("Invalid FEN en passant square %q", tokens[3])
 		}
 		b.enpassant = res
 	}
 
 	if len(tokens) > 4 {
-		result, _ := strconv.Atoi(tokens[4])
+		result, err := strconv.ParseUint(tokens[4], 10, 8)
+		if err != nil {
+			return b, fmt.Errorf("Invalid FEN halfmove clock %q", tokens[4])
+		}
 		b.Halfmoveclock = uint8(result)
 	}
 
 	if len(tokens) > 5 {
-		result, _ := strconv.Atoi(tokens[5])
+		result, err := strconv.ParseUint(tokens[5], 10, 16)
+		if err != nil {
+			return b, fmt.Errorf("Invalid FEN fullmove number %q", tokens[5])
+		}
 		b.Fullmoveno = uint16(result)
 	}
 	b.hash = recomputeBoardHash(&b)
-	return b
+	return b, nil
+}
+
+// Checks that a syntactically valid board describes a legal position.
+func (b *Board) validate() error {
+	if bits.OnesCount64(b.White.Kings) != 1 {
+		return fmt.Errorf("Invalid FEN piece placement: white has %d kings",
+			bits.OnesCount64(b.White.Kings))
+	}
+	if bits.OnesCount64(b.Black.Kings) != 1 {
+		return fmt.Errorf("Invalid FEN piece placement: black has %d kings",
+			bits.OnesCount64(b.Black.Kings))
+	}
+	if (b.White.Pawns|b.Black.Pawns)&(onlyRank[0]|onlyRank[7]) != 0 {
+		return errors.New("Invalid FEN piece placement: pawns on the first or last rank")
+	}
+	if bits.OnesCount64(b.White.Pawns) > 8 || bits.OnesCount64(b.White.All) > 16 {
+		return errors.New("Invalid FEN piece placement: white has too many pieces")
+	}
+	if bits.OnesCount64(b.Black.Pawns) > 8 || bits.OnesCount64(b.Black.All) > 16 {
+		return errors.New("Invalid FEN piece placement: black has too many pieces")
+	}
+	var oppKingLocation uint8
+	if b.Wtomove {
+		oppKingLocation = uint8(bits.TrailingZeros64(b.Black.Kings))
+	} else {
+		oppKingLocation = uint8(bits.TrailingZeros64(b.White.Kings))
+	}
+	if b.UnderDirectAttack(!b.Wtomove, oppKingLocation) {
+		return errors.New("Invalid FEN side to move: the side not to move is in check")
+	}
+
+	for right := uint8(0); right < 4; right++ {
+		if b.castlerights&(1<<right) == 0 {
+			continue
+		}
+		color, ourPieces, backRank := "white", &(b.White), onlyRank[0]
+		if right >= 2 {
+			color, ourPieces, backRank = "black", &(b.Black), onlyRank[7]
+		}
+		side := "queenside"
+		if right%2 == 1 {
+			side = "kingside"
+		}
+		rook := b.castlerooks[right]
+		kings := ourPieces.Kings & backRank
+		kingFile := uint8(bits.TrailingZeros64(kings) % 8)
+		if kings == 0 || ourPieces.Rooks&(uint64(1)<<rook) == 0 || (right%2 == 1) != (rook%8 > kingFile) {
+			return fmt.Errorf("Invalid FEN castling rights: %s cannot castle %s without a king on its back rank and a rook on %v",
+				color, side, IndexToAlgebraic(Square(rook)))
+		}
+		// Outside Chess960, the king and rook must be on their standard squares
+		standardKing, standardRook := uint8(4)+uint8(right/2)*56, [4]uint8{0, 7, 56, 63}[right]
+		if !b.Chess960 && (kings != uint64(1)<<standardKing || rook != standardRook) {
+			return fmt.Errorf("Invalid FEN castling rights: %s cannot castle %s without a king on %v and a rook on %v",
+				color, side, IndexToAlgebraic(Square(standardKing)), IndexToAlgebraic(Square(standardRook)))
+		}
+	}
+
+	if b.enpassant != 0 {
+		// The square the pushed pawn passed over (on the rank that parseFenStrict
+		// has checked), where it now stands, and where it came from
+		var pawnLocation, originLocation uint8
+		var oppPawns uint64
+		if b.Wtomove {
+			pawnLocation, originLocation = b.enpassant-8, b.enpassant+8
+			oppPawns = b.Black.Pawns
+		} else {
+			pawnLocation, originLocation = b.enpassant+8, b.enpassant-8
+			oppPawns = b.White.Pawns
+		}
+		ep := IndexToAlgebraic(Square(b.enpassant))
+		allPieces := b.White.All | b.Black.All
+		if allPieces&((uint64(1)<<b.enpassant)|(uint64(1)<<originLocation)) != 0 {
+			return fmt.Errorf("Invalid FEN en passant square %v: a pawn could not have just passed over it", ep)
+		}
+		if oppPawns&(uint64(1)<<pawnLocation) == 0 {
+			return fmt.Errorf("Invalid FEN en passant square %v: no pawn was just pushed past it", ep)
+		}
+	}
+	return nil
 }
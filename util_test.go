@@ -1,6 +1,7 @@
 package dragontoothmg
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -116,3 +117,114 @@ func TestToFen(t *testing.T) {
 		}
 	}
 }
+
+func TestChess960Fen(t *testing.T) {
+	// Shredder-FEN input, with the expected X-FEN output
+	fenTests := map[string]string{
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9": "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9",
+		"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9":       "b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w KQ - 1 9",
+		"rrk5/8/8/8/8/8/8/RRK5 w Bb - 0 1":                                  "rrk5/8/8/8/8/8/8/RRK5 w Bb - 0 1",
+		"1r1k1r1r/8/8/8/8/8/8/R2K1RR1 w FAhb - 0 1":                         "1r1k1r1r/8/8/8/8/8/8/R2K1RR1 w FQkq - 0 1",
+	}
+	for fen, xfen := range fenTests {
+		b, err := ParseFenStrict(fen)
+		if err != nil {
+			t.Error("Failed to parse Chess960 FEN", fen, "\n", err)
+			continue
+		}
+		if !b.Chess960 {
+			t.Error("Chess960 was not detected for", fen)
+		}
+		if b.ToShredderFen() != fen {
+			t.Error("Error serializing Shredder-FEN.\nOutput:  ", b.ToShredderFen(), "\nExpected:", fen)
+		}
+		if b.ToFen() != xfen {
+			t.Error("Error serializing X-FEN.\nOutput:  ", b.ToFen(), "\nExpected:", xfen)
+		}
+		if xb, err := ParseChess960Fen(xfen); err != nil || xb != b {
+			t.Error("X-FEN", xfen, "did not parse to the same position as", fen, "\n", err)
+		}
+	}
+	// KQkq only denote Chess960 castling when asked for
+	xfen := "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9"
+	if _, err := ParseFenStrict(xfen); err == nil {
+		t.Error("ParseFenStrict accepted Chess960 castling rights in", xfen)
+	}
+	if b, err := ParseChess960Fen(Startpos); err != nil || !b.Chess960 || b.ToFen() != Startpos {
+		t.Error("Wrong Chess960 board for the starting position:", b.ToFen(), err)
+	}
+	// Standard positions are only written in Shredder-FEN for Chess960 boards
+	b := ParseFen(Startpos)
+	if b.Chess960 || b.ToShredderFen() != Startpos {
+		t.Error("Wrong Shredder-FEN for the starting position:", b.ToShredderFen())
+	}
+	b.Chess960 = true
+	if b.ToShredderFen() != "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1" || b.ToFen() != Startpos {
+		t.Error("Wrong FEN for the Chess960 starting position:", b.ToShredderFen(), b.ToFen())
+	}
+}
+
+func TestParseFenStrict(t *testing.T) {
+	valid := []string{
+		Startpos,
+		"1Q2rk2/2p2p2/1n4b1/N7/2B1Pp1q/2B4P/1QPP1P2/4K2R b K e3 4 30",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 0",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - -",
+	}
+	for _, fen := range valid {
+		b, err := ParseFenStrict(fen)
+		if err != nil {
+			t.Error("Strict FEN parsing rejected a valid FEN:", fen, "\n", err)
+		} else if b != ParseFen(fen) {
+			t.Error("Strict FEN parsing disagrees with ParseFen for", fen)
+		}
+	}
+	// Each invalid FEN, with a word that should appear in the error
+	invalid := map[string]string{
+		"": "fields",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq":          "fields",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 x":  "fields",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1":             "placement",
+		"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":    "placement",
+		"rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":   "placement",
+		"rnbqkbnr/ppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1":     "placement",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1":    "placement",
+		"rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1":      "placement",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBKKBNR w kq - 0 1":      "placement",
+		"rnbqkbnP/pppppppp/8/8/8/8/PPPPPPP1/RNBQKBNR w KQq - 0 1":     "placement",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1":    "side to move",
+		"rnbqkbnr/ppppp1pp/8/5p1Q/8/8/PPPPPPPP/RNB1KBNR w KQkq - 0 1": "side to move",
+		"1Q2rk2/2p2p2/1n4b1/N7/2B1Pp1q/2B4P/1QPP4/4K2R b K e3 4 30":   "side to move",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkqq - 0 1":   "castling",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQxq - 0 1":    "castling",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN1 w KQkq - 0 1":    "castling",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1BNR w KQkq - 0 1":    "placement",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBK1BNR w Qkq - 0 1":     "castling",
+		"rnbqkbnr/pppppppp/8/8/8/4K3/PPPPPPPP/RNBQ1BNR w Qkq - 0 1":   "castling",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w Ekq - 0 1":     "castling",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w Gkq - 0 1":     "castling",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAhah - 0 1":   "castling",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1":   "en passant",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e3 0 1":   "en passant",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq a1 0 1":   "en passant",
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq a1 0 1": "en passant",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e6 0 1":   "en passant",
+		"rnbqkbnr/pppp1ppp/4p3/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e6 0 1": "en passant",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - x 1":    "halfmove",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 256 1":  "halfmove",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 -1":   "fullmove",
+	}
+	for fen, field := range invalid {
+		_, err := ParseFenStrict(fen)
+		if err == nil {
+			t.Error("Strict FEN parsing accepted an invalid FEN:", fen)
+		} else if !strings.Contains(err.Error(), field) {
+			t.Error("Strict FEN parsing reported the wrong field for", fen, "\n", err)
+		}
+	}
+	// The lenient parser produces a blank board for malformed input
+	if ParseFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1") != (Board{}) {
+		t.Error("ParseFen did not return a blank board for a malformed FEN.")
+	}
+}